@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
 	"log"
 	"math/big"
@@ -8,12 +9,15 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+	_ "time/tzdata"
 
 	"railway-dispatcher/internal/config"
 	"railway-dispatcher/internal/database"
 	"railway-dispatcher/internal/handlers"
 	"railway-dispatcher/internal/middleware"
 	"railway-dispatcher/internal/models"
+	"railway-dispatcher/internal/services"
 	"railway-dispatcher/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -22,6 +26,34 @@ import (
 func main() {
 	cfg := config.Load()
 	utils.InitJWT(cfg.JWTSecret)
+	if ttl, err := time.ParseDuration(cfg.AccessTokenTTL); err == nil && ttl > 0 {
+		utils.AccessTokenTTL = ttl
+	} else {
+		log.Printf("Неверное значение ACCESS_TOKEN_TTL (%s), используется %s", cfg.AccessTokenTTL, utils.AccessTokenTTL)
+	}
+	if ttl, err := time.ParseDuration(cfg.RefreshTokenTTL); err == nil && ttl > 0 {
+		utils.RefreshTokenTTL = ttl
+	} else {
+		log.Printf("Неверное значение REFRESH_TOKEN_TTL (%s), используется %s", cfg.RefreshTokenTTL, utils.RefreshTokenTTL)
+	}
+
+	if turnaround, err := time.ParseDuration(cfg.TrainTurnaround); err == nil {
+		services.TrainTurnaround = turnaround
+	} else {
+		log.Printf("Неверное значение TRAIN_TURNAROUND (%s), используется %s", cfg.TrainTurnaround, services.TrainTurnaround)
+	}
+
+	for _, role := range strings.Split(cfg.TwoFactorRoles, ",") {
+		role := models.Role(strings.TrimSpace(role))
+		if role == "" {
+			continue
+		}
+		if !role.Valid() {
+			log.Printf("Неизвестная роль в TWO_FACTOR_ROLES: %s", role)
+			continue
+		}
+		services.TwoFactorRoles[role] = true
+	}
 
 	if err := database.Init(cfg); err != nil {
 		log.Fatal("Ошибка подключения к БД:", err)
@@ -29,12 +61,21 @@ func main() {
 
 	createDefaultAdmin()
 
+	statusInterval, err := time.ParseDuration(cfg.StatusInterval)
+	if err != nil || statusInterval <= 0 {
+		log.Printf("Неверное значение STATUS_INTERVAL (%s), используется 1m", cfg.StatusInterval)
+		statusInterval = time.Minute
+	}
+	statusEngine := &services.StatusEngine{Interval: statusInterval}
+	go statusEngine.Run(context.Background())
+
 	r := gin.Default()
 
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, If-Match")
+		c.Header("Access-Control-Expose-Headers", "X-Total-Count, X-Next-Cursor, ETag")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -44,13 +85,24 @@ func main() {
 
 	r.POST("/api/login", handlers.Login)
 	r.POST("/api/register", handlers.Register)
+	r.POST("/api/refresh", handlers.Refresh)
+	r.POST("/api/login/2fa", middleware.PreAuthMiddleware(utils.PurposeTwoFactor), handlers.LoginTwoFactor)
+	r.POST("/api/login/2fa/setup", middleware.PreAuthMiddleware(utils.PurposeTwoFactorSetup), handlers.SetupTwoFactor)
+	r.POST("/api/login/2fa/enable", middleware.PreAuthMiddleware(utils.PurposeTwoFactorSetup), handlers.EnableTwoFactor)
 	r.GET("/api/schedules", handlers.GetSchedules)
+	r.GET("/api/schedules/export", handlers.ExportSchedules)
 	r.GET("/api/stations", handlers.GetStations)
+	r.GET("/api/station-distances", handlers.GetStationDistances)
 
 	api := r.Group("/api")
 	api.Use(middleware.AuthMiddleware())
 	{
 		api.GET("/me", handlers.Me)
+		api.POST("/logout", handlers.Logout)
+		api.POST("/2fa/setup", handlers.SetupTwoFactor)
+		api.POST("/2fa/enable", handlers.EnableTwoFactor)
+		api.POST("/2fa/disable", handlers.DisableTwoFactor)
+		api.POST("/2fa/recovery-codes", handlers.RegenerateRecoveryCodes)
 
 		api.GET("/stats", handlers.GetStats)
 
@@ -58,18 +110,51 @@ func main() {
 		api.GET("/trains/:id", handlers.GetTrain)
 		api.POST("/trains", middleware.RequireRole(models.RoleAdmin, models.RoleCarrier, models.RoleDispatcher), handlers.CreateTrain)
 		api.PUT("/trains/:id", middleware.RequireRole(models.RoleAdmin, models.RoleCarrier, models.RoleDispatcher), handlers.UpdateTrain)
+		api.PATCH("/trains/:id", middleware.RequireRole(models.RoleAdmin, models.RoleCarrier, models.RoleDispatcher), handlers.PatchTrain)
 		api.DELETE("/trains/:id", middleware.RequireRole(models.RoleAdmin), handlers.DeleteTrain)
 
+		api.GET("/schedules/slots", handlers.FindScheduleSlots)
 		api.GET("/schedules/:id", handlers.GetSchedule)
+		api.GET("/schedules/:id/series", handlers.GetScheduleSeries)
+		api.GET("/journeys", handlers.SearchJourneys)
+		api.POST("/schedules/import", middleware.RequireRole(models.RoleAdmin, models.RoleCarrier, models.RoleDispatcher), handlers.ImportSchedules)
 		api.POST("/schedules", middleware.RequireRole(models.RoleAdmin, models.RoleCarrier, models.RoleDispatcher), handlers.CreateSchedule)
 		api.PUT("/schedules/:id", middleware.RequireRole(models.RoleAdmin, models.RoleCarrier, models.RoleDispatcher), handlers.UpdateSchedule)
+		api.PATCH("/schedules/:id", middleware.RequireRole(models.RoleAdmin, models.RoleCarrier, models.RoleDispatcher), handlers.PatchSchedule)
 		api.DELETE("/schedules/:id", middleware.RequireRole(models.RoleAdmin, models.RoleCarrier, models.RoleDispatcher), handlers.DeleteSchedule)
+		api.POST("/schedules/:id/cancel", middleware.RequireRole(models.RoleAdmin, models.RoleCarrier, models.RoleDispatcher), handlers.CancelSchedule)
+		api.POST("/schedules/:id/delay", middleware.RequireRole(models.RoleAdmin, models.RoleCarrier, models.RoleDispatcher), handlers.ReportDelay)
 
 		api.GET("/stations/:id", handlers.GetStation)
 		api.POST("/stations", middleware.RequireRole(models.RoleAdmin, models.RoleCarrier), handlers.CreateStation)
 		api.PUT("/stations/:id", middleware.RequireRole(models.RoleAdmin, models.RoleCarrier), handlers.UpdateStation)
+		api.PATCH("/stations/:id", middleware.RequireRole(models.RoleAdmin, models.RoleCarrier), handlers.PatchStation)
 		api.DELETE("/stations/:id", middleware.RequireRole(models.RoleAdmin), handlers.DeleteStation)
 
+		api.GET("/tracks", handlers.GetTracks)
+		api.GET("/tracks/:id", handlers.GetTrack)
+		api.POST("/tracks", middleware.RequireRole(models.RoleAdmin, models.RoleCarrier), handlers.CreateTrack)
+		api.PUT("/tracks/:id", middleware.RequireRole(models.RoleAdmin, models.RoleCarrier), handlers.UpdateTrack)
+		api.DELETE("/tracks/:id", middleware.RequireRole(models.RoleAdmin), handlers.DeleteTrack)
+
+		api.GET("/station-distances/:id", handlers.GetStationDistance)
+		api.POST("/station-distances", middleware.RequireRole(models.RoleAdmin, models.RoleCarrier), handlers.CreateStationDistance)
+		api.PUT("/station-distances/:id", middleware.RequireRole(models.RoleAdmin, models.RoleCarrier), handlers.UpdateStationDistance)
+		api.DELETE("/station-distances/:id", middleware.RequireRole(models.RoleAdmin), handlers.DeleteStationDistance)
+
+		api.GET("/maintenance-rules", handlers.GetMaintenanceRules)
+		api.GET("/maintenance-rules/:id", handlers.GetMaintenanceRule)
+		api.POST("/maintenance-rules", middleware.RequireRole(models.RoleAdmin), handlers.CreateMaintenanceRule)
+		api.PUT("/maintenance-rules/:id", middleware.RequireRole(models.RoleAdmin), handlers.UpdateMaintenanceRule)
+		api.DELETE("/maintenance-rules/:id", middleware.RequireRole(models.RoleAdmin), handlers.DeleteMaintenanceRule)
+
+		api.GET("/track-closures", handlers.GetTrackClosures)
+		api.GET("/track-closures/:id", handlers.GetTrackClosure)
+		api.GET("/track-closures/:id/impacted", handlers.GetClosureImpact)
+		api.POST("/track-closures", middleware.RequireRole(models.RoleAdmin), handlers.CreateTrackClosure)
+		api.PUT("/track-closures/:id", middleware.RequireRole(models.RoleAdmin), handlers.UpdateTrackClosure)
+		api.DELETE("/track-closures/:id", middleware.RequireRole(models.RoleAdmin), handlers.DeleteTrackClosure)
+
 		admin := api.Group("/users")
 		admin.Use(middleware.RequireRole(models.RoleAdmin))
 		{
@@ -77,6 +162,10 @@ func main() {
 			admin.GET("/:id", handlers.GetUser)
 			admin.PUT("/:id", handlers.UpdateUser)
 			admin.DELETE("/:id", handlers.DeleteUser)
+			admin.POST("/:id/approve", handlers.ApproveUser)
+			admin.POST("/:id/reject", handlers.RejectUser)
+			admin.POST("/:id/unlock", handlers.UnlockUser)
+			admin.POST("/:id/2fa/reset", handlers.ResetUserTwoFactor)
 		}
 
 		api.GET("/audit", middleware.RequireRole(models.RoleAdmin), handlers.GetAuditLogs)
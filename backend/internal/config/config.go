@@ -10,6 +10,13 @@ type Config struct {
 	DBPassword string
 	DBName     string
 	JWTSecret  string
+
+	AccessTokenTTL  string
+	RefreshTokenTTL string
+	TwoFactorRoles  string // Роли с обязательной 2FA через запятую, например "Admin,Carrier"
+
+	TrainTurnaround string
+	StatusInterval  string
 }
 
 func Load() *Config {
@@ -21,6 +28,13 @@ func Load() *Config {
 		DBPassword: getEnv("DB_PASSWORD", "postgres"),
 		DBName:     getEnv("DB_NAME", "railway_dispatcher"),
 		JWTSecret:  getEnv("JWT_SECRET", "super-secret-key-change-in-production"),
+
+		AccessTokenTTL:  getEnv("ACCESS_TOKEN_TTL", "15m"),
+		RefreshTokenTTL: getEnv("REFRESH_TOKEN_TTL", "720h"),
+		TwoFactorRoles:  getEnv("TWO_FACTOR_ROLES", ""),
+
+		TrainTurnaround: getEnv("TRAIN_TURNAROUND", "30m"),
+		StatusInterval:  getEnv("STATUS_INTERVAL", "1m"),
 	}
 }
 
@@ -26,11 +26,38 @@ func Init(cfg *config.Config) error {
 		return err
 	}
 
-	return DB.AutoMigrate(
+	if err := DB.AutoMigrate(
 		&models.User{},
+		&models.RefreshToken{},
 		&models.Train{},
 		&models.Station{},
+		&models.StationDistance{},
+		&models.Track{},
+		&models.MaintenanceRule{},
+		&models.TrackClosure{},
 		&models.Schedule{},
+		&models.RouteStop{},
 		&models.AuditLog{},
-	)
+	); err != nil {
+		return err
+	}
+
+	// Рейсы, созданные до описания путей станции, хранят только номер пути:
+	// привязываем их к пути станции отправления с тем же номером, чтобы
+	// проверка коллизий видела их вместе с рейсами на путях станции
+	if err := DB.Exec(`UPDATE schedules SET departure_track_id = tracks.id
+		FROM tracks
+		WHERE schedules.departure_track_id IS NULL AND schedules.arrival_track_id IS NULL
+			AND tracks.station_id = schedules.from_station_id AND tracks.number = schedules.track_number
+			AND tracks.deleted_at IS NULL`).Error; err != nil {
+		return err
+	}
+
+	// Уникальность перегона прежде распространялась и на удалённые записи
+	if DB.Migrator().HasIndex(&models.StationDistance{}, "idx_station_distance_pair") {
+		if err := DB.Migrator().DropIndex(&models.StationDistance{}, "idx_station_distance_pair"); err != nil {
+			return err
+		}
+	}
+	return nil
 }
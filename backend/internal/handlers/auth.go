@@ -1,15 +1,24 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/middleware"
 	"railway-dispatcher/internal/models"
+	"railway-dispatcher/internal/services"
 	"railway-dispatcher/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
+var loginLimiter = services.NewLoginLimiter(services.NewMemoryAttemptStore(time.Hour))
+
 type LoginRequest struct {
 	Login    string `json:"login" binding:"required"`
 	Password string `json:"password" binding:"required"`
@@ -28,29 +37,176 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	attempt, denial := loginLimiter.Begin(req.Login, c.ClientIP())
+	if denial != nil {
+		respondLoginDenied(c, denial)
+		return
+	}
+	defer attempt.Release()
+
 	var user models.User
 	if err := database.DB.Where("login = ?", req.Login).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный логин или пароль"})
+		loginFailed(c, attempt, req.Login, 0)
 		return
 	}
 
 	if !user.CheckPassword(req.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный логин или пароль"})
+		loginFailed(c, attempt, req.Login, user.ID)
 		return
 	}
 
-	token, err := utils.GenerateToken(&user)
+	switch user.Status {
+	case models.UserStatusPending:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Учётная запись ожидает подтверждения администратором"})
+		return
+	case models.UserStatusRejected:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Заявка на регистрацию отклонена"})
+		return
+	}
+
+	// С двухфакторной аутентификацией пароль даёт только промежуточный токен
+	// для ввода кода (или обязательного подключения TOTP); счётчик неудач
+	// сбрасывается после второго шага
+	if user.TOTPEnabled || services.TwoFactorRequired(user.Role) {
+		purpose := utils.PurposeTwoFactor
+		if !user.TOTPEnabled {
+			purpose = utils.PurposeTwoFactorSetup
+		}
+		token, err := utils.GeneratePreAuthToken(&user, purpose)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации токена"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor":     purpose,
+			"pre_auth_token": token,
+			"expires_in":     int(utils.PreAuthTokenTTL.Seconds()),
+		})
+		return
+	}
+
+	attempt.Succeed()
+	issueLoginTokens(c, &user, nil)
+}
+
+// issueLoginTokens завершает вход: выдаёт пару токенов и отвечает вместе с
+// пользователем и дополнительными полями extra.
+func issueLoginTokens(c *gin.Context, user *models.User, extra gin.H) {
+	pair, err := services.IssueTokens(user, sessionInfo(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации токена"})
 		return
 	}
+	respondTokens(c, user, pair, extra)
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"user":  user,
-	})
+func respondTokens(c *gin.Context, user *models.User, pair *services.TokenPair, extra gin.H) {
+	body := gin.H{
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"expires_in":    pair.ExpiresIn,
+		"user":          user,
+	}
+	for k, v := range extra {
+		body[k] = v
+	}
+	c.JSON(http.StatusOK, body)
 }
 
+// loginFailed учитывает неудачную попытку входа, записывает её в журнал
+// аудита и отвечает 401.
+func loginFailed(c *gin.Context, attempt *services.LoginAttempt, login string, userID uint) {
+	recordLoginFailure(c, attempt, login, userID, false)
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный логин или пароль"})
+}
+
+func recordLoginFailure(c *gin.Context, attempt *services.LoginAttempt, login string, userID uint, twoFactor bool) {
+	locked := attempt.Fail()
+	details := gin.H{"login": login}
+	if twoFactor {
+		details["two_factor"] = true
+	}
+	middleware.CreateAuditLog(c, models.ActionLoginFailed, models.EntityUser, userID, nil, details)
+	if locked {
+		middleware.CreateAuditLog(c, models.ActionLockout, models.EntityUser, userID, nil, gin.H{"login": login})
+	}
+}
+
+func respondLoginDenied(c *gin.Context, denial *services.LoginDenial) {
+	seconds := int(math.Ceil(denial.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	message := fmt.Sprintf("Слишком много попыток входа, повторите через %d с", seconds)
+	if denial.Locked {
+		message = fmt.Sprintf("Вход временно заблокирован, повторите через %d мин", (seconds+59)/60)
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": message, "retry_after": seconds})
+}
+
+func sessionInfo(c *gin.Context) services.SessionInfo {
+	return services.SessionInfo{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Refresh обменивает токен обновления на новую пару токенов. Каждый токен
+// обновления действует один раз.
+func Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+		return
+	}
+
+	user, pair, err := services.RotateRefreshToken(req.RefreshToken, sessionInfo(c))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка генерации токена"})
+		return
+	}
+
+	respondTokens(c, user, pair, nil)
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Logout отзывает токен обновления текущего сеанса. С ?all=true отзываются
+// все токены пользователя, включая уже выданные токены доступа.
+func Logout(c *gin.Context) {
+	var req LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+			return
+		}
+	}
+
+	userID, _ := c.Get("userID")
+	uid := userID.(uint)
+
+	var err error
+	if c.Query("all") == "true" {
+		err = services.RevokeUserTokens(database.DB, uid)
+	} else if req.RefreshToken != "" {
+		err = services.RevokeRefreshToken(req.RefreshToken, uid)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка отзыва токенов"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Выход выполнен"})
+}
+
+// Register создаёт учётную запись. Без входа доступны только роли Viewer и
+// Company; заявки на Carrier и Dispatcher ждут подтверждения администратором,
+// а роль Admin выдаётся только через UpdateUser.
 func Register(c *gin.Context) {
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -58,13 +214,25 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	user := models.User{
-		Login: req.Login,
-		Role:  req.Role,
+	if req.Role == "" {
+		req.Role = models.RoleViewer
+	}
+	if !req.Role.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неизвестная роль"})
+		return
+	}
+	if req.Role == models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Роль Admin может выдать только администратор"})
+		return
 	}
 
-	if user.Role == "" {
-		user.Role = models.RoleViewer
+	user := models.User{
+		Login:  req.Login,
+		Role:   req.Role,
+		Status: models.UserStatusActive,
+	}
+	if req.Role.RequiresApproval() {
+		user.Status = models.UserStatusPending
 	}
 
 	if err := user.SetPassword(req.Password); err != nil {
@@ -76,6 +244,15 @@ func Register(c *gin.Context) {
 		c.JSON(http.StatusConflict, gin.H{"error": "Пользователь уже существует"})
 		return
 	}
+	middleware.CreateAuditLog(c, models.ActionCreate, models.EntityUser, user.ID, nil, user)
+
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "Заявка на регистрацию отправлена администратору",
+			"user":    user,
+		})
+		return
+	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Пользователь создан",
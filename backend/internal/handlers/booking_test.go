@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"railway-dispatcher/internal/config"
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestCreateScheduleConcurrentBooking отправляет параллельные запросы на один
+// и тот же слот пути и проверяет, что сохраняется ровно один рейс, а
+// остальные запросы получают 409. Нужна база PostgreSQL: строка подключения
+// задаётся в TEST_DATABASE_DSN, без неё тест пропускается.
+func TestCreateScheduleConcurrentBooking(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN не задан")
+	}
+	t.Setenv("DATABASE_URL", dsn)
+	if err := database.Init(config.Load()); err != nil {
+		t.Fatalf("подключение к базе: %v", err)
+	}
+	gin.SetMode(gin.TestMode)
+
+	const n = 8
+	suffix := time.Now().UnixNano()
+
+	user := models.User{Login: fmt.Sprintf("booking-test-%d", suffix), PasswordHash: "-", Role: models.RoleAdmin}
+	station := models.Station{Name: fmt.Sprintf("Тест бронирования %d", suffix), Code: fmt.Sprintf("BT%d", suffix)}
+	mustCreate(t, &user)
+	mustCreate(t, &station)
+	track := models.Track{StationID: station.ID, Number: 1}
+	mustCreate(t, &track)
+
+	trains := make([]models.Train, n)
+	for i := range trains {
+		trains[i] = models.Train{Number: fmt.Sprintf("BT%d-%d", suffix, i)}
+		mustCreate(t, &trains[i])
+	}
+
+	t.Cleanup(func() {
+		database.DB.Unscoped().Where("departure_track_id = ?", track.ID).Delete(&models.Schedule{})
+		for i := range trains {
+			database.DB.Unscoped().Delete(&trains[i])
+		}
+		database.DB.Unscoped().Delete(&track)
+		database.DB.Unscoped().Delete(&station)
+		database.DB.Where("user_id = ?", user.ID).Delete(&models.AuditLog{})
+		database.DB.Unscoped().Delete(&user)
+	})
+
+	router := gin.New()
+	router.POST("/schedules", func(c *gin.Context) {
+		c.Set("userID", user.ID)
+		c.Set("userRole", user.Role)
+		c.Next()
+	}, CreateSchedule)
+
+	departure := time.Now().Add(24 * time.Hour).Truncate(time.Minute)
+	codes := make([]int, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		body, _ := json.Marshal(CreateScheduleRequest{
+			TrainID:          trains[i].ID,
+			DepartureTrackID: &track.ID,
+			FromStationID:    &station.ID,
+			DepartureTime:    departure,
+			ArrivalTime:      departure.Add(time.Hour),
+		})
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			req := httptest.NewRequest(http.MethodPost, "/schedules", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			codes[i] = w.Code
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	created, conflicts := 0, 0
+	for _, code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+			conflicts++
+		default:
+			t.Errorf("неожиданный код ответа %d", code)
+		}
+	}
+	if created != 1 || conflicts != n-1 {
+		t.Errorf("создано %d, коллизий %d; ожидалось 1 и %d", created, conflicts, n-1)
+	}
+
+	var count int64
+	database.DB.Model(&models.Schedule{}).Where("departure_track_id = ?", track.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("в базе %d рейсов на пути, ожидался 1", count)
+	}
+}
+
+func mustCreate(t *testing.T, value interface{}) {
+	t.Helper()
+	if err := database.DB.Create(value).Error; err != nil {
+		t.Fatalf("подготовка данных: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/middleware"
+	"railway-dispatcher/internal/models"
+	"railway-dispatcher/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errPropagation = errors.New("delay propagation failed")
+
+type ReportDelayRequest struct {
+	DelayMinutes        *int       `json:"delay_minutes"`
+	ActualDepartureTime *time.Time `json:"actual_departure_time"`
+	ActualArrivalTime   *time.Time `json:"actual_arrival_time"`
+	Propagate           *bool      `json:"propagate"`
+}
+
+func ReportDelay(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var schedule models.Schedule
+	if err := database.DB.First(&schedule, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Рейс не найден"})
+		return
+	}
+
+	if !canReportDelay(c, &schedule) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав"})
+		return
+	}
+
+	if !checkIfMatch(c, schedule.Version, schedule) {
+		return
+	}
+
+	var req ReportDelayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+		return
+	}
+	if req.DelayMinutes == nil && req.ActualDepartureTime == nil && req.ActualArrivalTime == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите опоздание или фактическое время"})
+		return
+	}
+	if schedule.Status == models.StatusCancelled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Рейс отменён"})
+		return
+	}
+
+	oldSchedule := schedule
+
+	if req.DelayMinutes != nil {
+		schedule.DelayMinutes = *req.DelayMinutes
+	}
+	services.ApplyActualTimes(&schedule, req.ActualDepartureTime, req.ActualArrivalTime)
+
+	if schedule.ActualDepartureTime != nil && schedule.ActualArrivalTime != nil &&
+		!schedule.ActualArrivalTime.After(*schedule.ActualDepartureTime) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Фактическое прибытие должно быть позже фактического отправления"})
+		return
+	}
+
+	schedule.Status = schedule.EffectiveStatus(time.Now())
+
+	// Сохранение рейса и распространение опоздания выполняются атомарно:
+	// при ошибке на любом следующем рейсе не остаётся частично сдвинутой цепочки.
+	var propagated []services.DelayChange
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveVersioned(tx, &schedule, &schedule.Version); err != nil {
+			return err
+		}
+		if req.Propagate != nil && !*req.Propagate {
+			return nil
+		}
+		changes, err := services.PropagateDelay(tx, &schedule)
+		if err != nil {
+			return errPropagation
+		}
+		propagated = changes
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errStaleVersion):
+			respondVersionConflict(c, &schedule, schedule.ID)
+		case errors.Is(err, errPropagation):
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка распространения опоздания"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения рейса"})
+		}
+		return
+	}
+
+	middleware.CreateAuditLog(c, models.ActionUpdate, models.EntitySchedule, schedule.ID, oldSchedule, schedule)
+	affected := []uint{schedule.ID}
+	for _, change := range propagated {
+		middleware.CreateAuditLog(c, models.ActionUpdate, models.EntitySchedule, change.ScheduleID,
+			gin.H{"delay_minutes": change.OldDelay},
+			gin.H{"delay_minutes": change.DelayMinutes, "caused_by_schedule_id": schedule.ID},
+		)
+		affected = append(affected, change.ScheduleID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"schedule":   schedule,
+		"propagated": propagated,
+		"conflicts":  validator.CheckDelayConflicts(affected),
+	})
+}
+
+// canReportDelay разрешает сообщать об опоздании диспетчеру и администратору
+// на любом рейсе, а также автору рейса и владельцу поезда.
+func canReportDelay(c *gin.Context, schedule *models.Schedule) bool {
+	userRole, _ := c.Get("userRole")
+	if role := userRole.(models.Role); role == models.RoleAdmin || role == models.RoleDispatcher {
+		return true
+	}
+	if canModifySchedule(c, schedule) {
+		return true
+	}
+	var train models.Train
+	if err := database.DB.First(&train, schedule.TrainID).Error; err != nil {
+		return false
+	}
+	return canModifyTrain(c, &train)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"railway-dispatcher/internal/models"
+	"railway-dispatcher/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxExportRows ограничивает число рейсов в одной выгрузке.
+const maxExportRows = 10000
+
+// ExportSchedules выгружает рейсы в формате ?format=csv|ics|gtfs с теми же
+// фильтрами, что и список рейсов. Календарь станции, поезда или пользователя
+// получается фильтрами station_id, train_id и created_by_id.
+func ExportSchedules(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "ics" && format != "gtfs" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неизвестный формат выгрузки"})
+		return
+	}
+
+	query, err := filterSchedules(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var total int64
+	query.Session(&gorm.Session{}).Count(&total)
+	if total > maxExportRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Выгрузка ограничена %d рейсами, уточните фильтры", maxExportRows)})
+		return
+	}
+
+	var schedules []models.Schedule
+	query.Order("departure_time ASC, id ASC").
+		Preload("Train").Preload("FromStation").Preload("ToStation").Preload("ArrivalTrack").
+		Preload("Stops", preloadStops).Preload("Stops.Station").Preload("Stops.Track").
+		Find(&schedules)
+
+	filename := "schedules-" + time.Now().Format("20060102")
+	var buf bytes.Buffer
+	var contentType string
+	switch format {
+	case "csv":
+		err = services.WriteScheduleCSV(&buf, schedules)
+		contentType = "text/csv; charset=utf-8"
+		filename += ".csv"
+	case "ics":
+		err = services.WriteScheduleICS(&buf, schedules, c.Query("name"))
+		contentType = "text/calendar; charset=utf-8"
+		filename += ".ics"
+	case "gtfs":
+		err = services.WriteScheduleGTFS(&buf, schedules)
+		contentType = "application/zip"
+		filename += "-gtfs.zip"
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка формирования выгрузки"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
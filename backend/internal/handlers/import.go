@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"railway-dispatcher/internal/middleware"
+	"railway-dispatcher/internal/models"
+	"railway-dispatcher/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxImportFileSize ограничивает размер загружаемого файла импорта.
+const maxImportFileSize = 20 << 20
+
+// ImportSchedules создаёт рейсы из CSV или архива GTFS. Каждый рейс проходит
+// все проверки, как при создании вручную, и сверяется с остальными рейсами
+// файла. Отчёт содержит результат по каждой строке; при ?dry_run=true ничего
+// не сохраняется. Рейсы создаются в одной транзакции только если ошибок нет.
+func ImportSchedules(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не передан файл"})
+		return
+	}
+	if header.Size > maxImportFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Файл слишком большой"})
+		return
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		format = "csv"
+		if strings.EqualFold(filepath.Ext(header.Filename), ".zip") {
+			format = "gtfs"
+		}
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не удалось прочитать файл"})
+		return
+	}
+	defer file.Close()
+
+	var rows []services.ImportRow
+	switch format {
+	case "csv":
+		rows, err = services.ParseScheduleCSV(file)
+	case "gtfs":
+		var data []byte
+		if data, err = io.ReadAll(file); err != nil {
+			break
+		}
+		var archive *zip.Reader
+		if archive, err = zip.NewReader(bytes.NewReader(data), int64(len(data))); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Файл GTFS должен быть zip-архивом"})
+			return
+		}
+		rows, err = services.ParseGTFS(archive)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неизвестный формат импорта"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Файл не содержит рейсов"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	uid := userID.(uint)
+
+	for i := range rows {
+		row := &rows[i]
+		if row.Error != "" {
+			continue
+		}
+		row.Schedule.CreatedByID = &uid
+		row.Schedule.Status = models.StatusScheduled
+		row.Schedule.Recurrence = models.RecurrenceNone
+		if r := checkSchedule(row.Schedule, false); r != nil {
+			row.Error, _ = r.body["error"].(string)
+		}
+	}
+	services.ValidateImportBatch(rows)
+
+	var booked []*models.Schedule
+	failed := 0
+	for i := range rows {
+		if rows[i].Error != "" {
+			failed++
+		} else {
+			booked = append(booked, rows[i].Schedule)
+		}
+	}
+
+	if c.Query("dry_run") == "true" {
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "total": len(rows), "failed": failed, "rows": rows})
+		return
+	}
+	if failed > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Импорт содержит ошибки, рейсы не созданы", "total": len(rows), "failed": failed, "rows": rows})
+		return
+	}
+
+	err = validator.BookSchedules(booked, func(tx *gorm.DB) error {
+		for i := range rows {
+			if err := tx.Create(rows[i].Schedule).Error; err != nil {
+				return err
+			}
+			rows[i].ScheduleID = rows[i].Schedule.ID
+		}
+		return nil
+	})
+	if err != nil {
+		respondBookingError(c, err, "Ошибка импорта рейсов")
+		return
+	}
+
+	ids := make([]uint, len(rows))
+	for i := range rows {
+		ids[i] = rows[i].ScheduleID
+	}
+	middleware.CreateAuditLog(c, models.ActionCreate, models.EntityScheduleImport, 0, nil, gin.H{
+		"format":       format,
+		"file":         header.Filename,
+		"schedule_ids": ids,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"total": len(rows), "created": len(rows), "rows": rows})
+}
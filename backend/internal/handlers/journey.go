@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"railway-dispatcher/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var journeyPlanner = &services.JourneyPlanner{}
+
+func SearchJourneys(c *gin.Context) {
+	fromID, errFrom := strconv.ParseUint(c.Query("from"), 10, 64)
+	toID, errTo := strconv.ParseUint(c.Query("to"), 10, 64)
+	if errFrom != nil || errTo != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не указаны станции отправления и назначения"})
+		return
+	}
+
+	after := time.Now()
+	if date := c.Query("date"); date != "" {
+		day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат даты, ожидается ГГГГ-ММ-ДД"})
+			return
+		}
+		after = day
+	}
+	if value := c.Query("after"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат времени"})
+			return
+		}
+		after = t
+	}
+
+	days := queryInt(c, "days", 1)
+	if days < 1 || days > 14 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Интервал поиска — от 1 до 14 дней"})
+		return
+	}
+
+	minTransfer := queryInt(c, "min_transfer", 30)
+	if minTransfer < 0 {
+		minTransfer = 0
+	}
+
+	query := services.JourneyQuery{
+		FromStationID: uint(fromID),
+		ToStationID:   uint(toID),
+		After:         after,
+		Window:        time.Duration(days) * 24 * time.Hour,
+		MaxTransfers:  queryInt(c, "max_transfers", 2),
+		MinTransfer:   time.Duration(minTransfer) * time.Minute,
+		Limit:         queryInt(c, "limit", 10),
+	}
+
+	journeys, err := journeyPlanner.Plan(query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if journeys == nil {
+		journeys = []services.Journey{}
+	}
+	c.JSON(http.StatusOK, journeys)
+}
+
+func queryInt(c *gin.Context, key string, fallback int) int {
+	if value, err := strconv.Atoi(c.Query(key)); err == nil {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/middleware"
+	"railway-dispatcher/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateMaintenanceRuleRequest struct {
+	StationID     *uint             `json:"station_id"`
+	TrackID       *uint             `json:"track_id"`
+	TrainType     *models.TrainType `json:"train_type"`
+	PrevTrainType *models.TrainType `json:"prev_train_type"`
+	Minutes       *int              `json:"minutes" binding:"required,gte=0,lte=1440"`
+	Description   string            `json:"description"`
+}
+
+func GetMaintenanceRules(c *gin.Context) {
+	query, err := filterIDs(c, database.DB.Model(&models.MaintenanceRule{}), "station_id", "track_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var rules []models.MaintenanceRule
+	query.Preload("Station").Preload("Track").Order("id ASC").Find(&rules)
+	c.JSON(http.StatusOK, rules)
+}
+
+func GetMaintenanceRule(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var rule models.MaintenanceRule
+	if err := database.DB.Preload("Station").Preload("Track").First(&rule, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Правило не найдено"})
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+func validateMaintenanceRuleRequest(c *gin.Context, req *CreateMaintenanceRuleRequest) bool {
+	if req.StationID != nil {
+		var station models.Station
+		if err := database.DB.First(&station, *req.StationID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Станция не найдена"})
+			return false
+		}
+	}
+
+	if req.TrackID != nil {
+		var track models.Track
+		if err := database.DB.First(&track, *req.TrackID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Путь не найден"})
+			return false
+		}
+		if req.StationID != nil && *req.StationID != track.StationID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Путь не принадлежит указанной станции"})
+			return false
+		}
+	}
+
+	for _, t := range []*models.TrainType{req.TrainType, req.PrevTrainType} {
+		if t == nil {
+			continue
+		}
+		switch *t {
+		case models.TrainTypeCargo, models.TrainTypeService, models.TrainTypePassager:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неизвестный тип поезда"})
+			return false
+		}
+	}
+
+	return true
+}
+
+func CreateMaintenanceRule(c *gin.Context) {
+	var req CreateMaintenanceRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+		return
+	}
+
+	if !validateMaintenanceRuleRequest(c, &req) {
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	uid := userID.(uint)
+
+	rule := models.MaintenanceRule{
+		StationID:     req.StationID,
+		TrackID:       req.TrackID,
+		TrainType:     req.TrainType,
+		PrevTrainType: req.PrevTrainType,
+		Minutes:       *req.Minutes,
+		Description:   req.Description,
+		CreatedByID:   &uid,
+	}
+
+	if err := database.DB.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания правила"})
+		return
+	}
+
+	middleware.CreateAuditLog(c, models.ActionCreate, models.EntityMaintenanceRule, rule.ID, nil, rule)
+	c.JSON(http.StatusCreated, rule)
+}
+
+func UpdateMaintenanceRule(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var rule models.MaintenanceRule
+	if err := database.DB.First(&rule, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Правило не найдено"})
+		return
+	}
+
+	oldRule := rule
+
+	var req CreateMaintenanceRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+		return
+	}
+
+	if !validateMaintenanceRuleRequest(c, &req) {
+		return
+	}
+
+	rule.StationID = req.StationID
+	rule.TrackID = req.TrackID
+	rule.TrainType = req.TrainType
+	rule.PrevTrainType = req.PrevTrainType
+	rule.Minutes = *req.Minutes
+	rule.Description = req.Description
+
+	if err := database.DB.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения правила"})
+		return
+	}
+	middleware.CreateAuditLog(c, models.ActionUpdate, models.EntityMaintenanceRule, rule.ID, oldRule, rule)
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func DeleteMaintenanceRule(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var rule models.MaintenanceRule
+	if err := database.DB.First(&rule, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Правило не найдено"})
+		return
+	}
+
+	database.DB.Delete(&rule)
+	middleware.CreateAuditLog(c, models.ActionDelete, models.EntityMaintenanceRule, rule.ID, rule, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Правило удалено"})
+}
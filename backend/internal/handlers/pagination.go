@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const maxPageLimit = 1000
+
+var gormSchemaCache sync.Map
+
+type sortKind int
+
+const (
+	sortString sortKind = iota
+	sortInt
+	sortFloat
+	sortTime
+)
+
+type sortField struct {
+	column string
+	kind   sortKind
+}
+
+// listSpec описывает допустимые поля сортировки и размер страницы списка.
+// Нулевой defaultLimit сохраняет прежнее поведение списка: без limit и cursor
+// возвращаются все записи, а постраничный обход идёт страницами по maxPageLimit.
+type listSpec struct {
+	sorts        map[string]sortField
+	defaultSort  string
+	defaultDesc  bool
+	defaultLimit int
+}
+
+type pageCursor struct {
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+type page struct {
+	query  *gorm.DB
+	field  sortField
+	limit  int
+	parsed *schema.Schema
+}
+
+// paginate применяет к отфильтрованному запросу сортировку и курсор из параметров
+// sort (имя поля, "-" в начале — по убыванию), cursor и limit, а общее число
+// записей возвращает в заголовке X-Total-Count.
+func paginate(c *gin.Context, query *gorm.DB, model interface{}, spec listSpec) (*page, error) {
+	sortName := c.DefaultQuery("sort", spec.defaultSort)
+	desc := spec.defaultDesc
+	if c.Query("sort") != "" {
+		desc = strings.HasPrefix(sortName, "-")
+		sortName = strings.TrimPrefix(sortName, "-")
+	}
+	field, ok := spec.sorts[sortName]
+	if !ok {
+		return nil, fmt.Errorf("недопустимое поле сортировки: %s", sortName)
+	}
+
+	limit := spec.defaultLimit
+	if limit == 0 && c.Query("cursor") != "" {
+		limit = maxPageLimit
+	}
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, errors.New("неверное значение limit")
+		}
+		limit = n
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Model(model).Count(&total).Error; err != nil {
+		return nil, err
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+
+	op, dir := ">", "ASC"
+	if desc {
+		op, dir = "<", "DESC"
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, value, err := decodeCursor(raw, field.kind)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(
+			fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", field.column, op, field.column, op),
+			value, value, cursor.ID,
+		)
+	}
+
+	parsed, err := schema.Parse(model, &gormSchemaCache, query.NamingStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	query = query.Order(fmt.Sprintf("%s %s, id %s", field.column, dir, dir))
+	if limit > 0 {
+		query = query.Limit(limit + 1)
+	}
+	return &page{query: query, field: field, limit: limit, parsed: parsed}, nil
+}
+
+// finish обрезает лишнюю запись и выставляет заголовок X-Next-Cursor.
+func (p *page) finish(c *gin.Context, dest interface{}) {
+	items := reflect.ValueOf(dest).Elem()
+	if p.limit == 0 || items.Len() <= p.limit {
+		return
+	}
+	items.Set(items.Slice(0, p.limit))
+
+	last := items.Index(p.limit - 1)
+	idField := p.parsed.LookUpField("id")
+	valueField := p.parsed.LookUpField(p.field.column)
+	if idField == nil || valueField == nil {
+		return
+	}
+	id, _ := idField.ValueOf(c, last)
+	value, _ := valueField.ValueOf(c, last)
+
+	c.Header("X-Next-Cursor", encodeCursor(value, id.(uint)))
+}
+
+func encodeCursor(value interface{}, id uint) string {
+	var text string
+	switch v := value.(type) {
+	case time.Time:
+		text = v.UTC().Format(time.RFC3339Nano)
+	default:
+		text = fmt.Sprint(v)
+	}
+	data, _ := json.Marshal(pageCursor{Value: text, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string, kind sortKind) (*pageCursor, interface{}, error) {
+	invalid := errors.New("неверный курсор")
+
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, nil, invalid
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, nil, invalid
+	}
+
+	var value interface{}
+	switch kind {
+	case sortInt:
+		value, err = strconv.ParseInt(cursor.Value, 10, 64)
+	case sortFloat:
+		value, err = strconv.ParseFloat(cursor.Value, 64)
+	case sortTime:
+		value, err = time.Parse(time.RFC3339Nano, cursor.Value)
+	default:
+		value = cursor.Value
+	}
+	if err != nil {
+		return nil, nil, invalid
+	}
+
+	return &cursor, value, nil
+}
+
+// parseTimeQuery разбирает параметр-время в формате RFC 3339 или ГГГГ-ММ-ДД.
+func parseTimeQuery(c *gin.Context, key string) (*time.Time, bool, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, false, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, false, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return &t, true, nil
+	}
+	return nil, false, fmt.Errorf("неверный формат параметра %s", key)
+}
+
+// filterTimeRange ограничивает column параметрами date_from и date_to;
+// дата без времени в date_to включает весь день.
+func filterTimeRange(c *gin.Context, query *gorm.DB, column string) (*gorm.DB, error) {
+	from, _, err := parseTimeQuery(c, "date_from")
+	if err != nil {
+		return nil, err
+	}
+	if from != nil {
+		query = query.Where(column+" >= ?", *from)
+	}
+
+	to, dateOnly, err := parseTimeQuery(c, "date_to")
+	if err != nil {
+		return nil, err
+	}
+	if to != nil {
+		if dateOnly {
+			*to = to.AddDate(0, 0, 1)
+		}
+		query = query.Where(column+" < ?", *to)
+	}
+
+	return query, nil
+}
+
+// filterIDs добавляет условие равенства для каждого указанного числового параметра.
+func filterIDs(c *gin.Context, query *gorm.DB, columns ...string) (*gorm.DB, error) {
+	for _, column := range columns {
+		value := c.Query(column)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("неверное значение параметра %s", column)
+		}
+		query = query.Where(column+" = ?", id)
+	}
+	return query, nil
+}
+
+// queryList разбивает параметр со значениями через запятую.
+func queryList(c *gin.Context, key string) []string {
+	value := c.Query(key)
+	if value == "" {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// mergePatch применяет JSON Merge Patch (RFC 7386): объекты сливаются
+// рекурсивно, null удаляет поле, остальные значения заменяются целиком.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
+
+// bindRequest заполняет запрос из тела: для PUT тело заменяет запрос целиком,
+// для PATCH накладывается на req, уже заполненный текущим состоянием записи.
+func bindRequest(c *gin.Context, req interface{}, patch bool) bool {
+	if !patch {
+		if err := c.ShouldBindJSON(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+			return false
+		}
+		return true
+	}
+
+	if err := bindMergePatch(c, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+		return false
+	}
+	return true
+}
+
+func bindMergePatch(c *gin.Context, req interface{}) error {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	var patch interface{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return err
+	}
+	if _, ok := patch.(map[string]interface{}); !ok {
+		return errors.New("тело PATCH должно быть JSON-объектом")
+	}
+
+	current, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(current, &doc); err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		return err
+	}
+
+	// Удалённые через null поля должны получить нулевое значение
+	value := reflect.ValueOf(req).Elem()
+	value.Set(reflect.Zero(value.Type()))
+	if err := json.Unmarshal(merged, req); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(req)
+}
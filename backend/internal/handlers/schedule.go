@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"railway-dispatcher/internal/database"
@@ -12,36 +15,134 @@ import (
 	"railway-dispatcher/internal/services"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 var validator = &services.ScheduleValidator{}
 var physicsValidator = &services.PhysicsValidator{}
+var trainValidator = &services.TrainAvailabilityValidator{}
 
 type CreateScheduleRequest struct {
-	TrainID       uint                  `json:"train_id" binding:"required"`
-	TrackNumber   int                   `json:"track_number" binding:"required"`
-	DepartureTime time.Time             `json:"departure_time" binding:"required"`
-	ArrivalTime   time.Time             `json:"arrival_time" binding:"required"`
-	Status        models.ScheduleStatus `json:"status"`
-	Recurrence    models.Recurrence     `json:"recurrence"`
-	FromStationID *uint                 `json:"from_station_id"`
-	ToStationID   *uint                 `json:"to_station_id"`
-	RecurCount    int                   `json:"recur_count"`
+	TrainID          uint                  `json:"train_id" binding:"required"`
+	TrackNumber      int                   `json:"track_number"`
+	DepartureTrackID *uint                 `json:"departure_track_id"`
+	ArrivalTrackID   *uint                 `json:"arrival_track_id"`
+	DepartureTime    time.Time             `json:"departure_time" binding:"required"`
+	ArrivalTime      time.Time             `json:"arrival_time" binding:"required"`
+	Status           models.ScheduleStatus `json:"status"`
+	Recurrence       models.Recurrence     `json:"recurrence"`
+	FromStationID    *uint                 `json:"from_station_id"`
+	ToStationID      *uint                 `json:"to_station_id"`
+	RecurCount       int                   `json:"recur_count"`
+	RRule            string                `json:"rrule"`       // Правило повторения RFC 5545
+	ExDates          []string              `json:"exdates"`     // Даты-исключения ГГГГ-ММ-ДД
+	OnConflict       string                `json:"on_conflict"` // reject (по умолчанию) или skip
+	Stops            []RouteStopRequest    `json:"stops" binding:"dive"`
+}
+
+type RouteStopRequest struct {
+	StationID     uint      `json:"station_id" binding:"required"`
+	TrackID       *uint     `json:"track_id"`
+	ArrivalTime   time.Time `json:"arrival_time" binding:"required"`
+	DepartureTime time.Time `json:"departure_time"`
+	DwellMinutes  int       `json:"dwell_minutes" binding:"gte=0"`
+}
+
+func buildRouteStops(reqs []RouteStopRequest) []models.RouteStop {
+	stops := make([]models.RouteStop, 0, len(reqs))
+	for i, r := range reqs {
+		stops = append(stops, models.RouteStop{
+			Sequence:      i + 1,
+			StationID:     r.StationID,
+			TrackID:       r.TrackID,
+			ArrivalTime:   r.ArrivalTime,
+			DepartureTime: r.DepartureTime,
+			DwellMinutes:  r.DwellMinutes,
+		})
+	}
+	return stops
+}
+
+func preloadStops(db *gorm.DB) *gorm.DB {
+	return db.Order("sequence ASC")
+}
+
+var scheduleListSpec = listSpec{
+	sorts: map[string]sortField{
+		"id":             {"id", sortInt},
+		"departure_time": {"departure_time", sortTime},
+		"arrival_time":   {"arrival_time", sortTime},
+		"created_at":     {"created_at", sortTime},
+		"track_number":   {"track_number", sortInt},
+		"status":         {"status", sortString},
+	},
+	defaultSort: "departure_time",
+}
+
+// filterSchedules строит запрос рейсов по параметрам списка:
+// date_from, date_to, from_station_id, to_station_id, train_id,
+// track_number, track_id, station_id (отправление или прибытие),
+// status (через запятую), created_by_id.
+func filterSchedules(c *gin.Context) (*gorm.DB, error) {
+	query := database.DB.Model(&models.Schedule{})
+
+	query, err := filterTimeRange(c, query, "departure_time")
+	if err != nil {
+		return nil, err
+	}
+	query, err = filterIDs(c, query, "from_station_id", "to_station_id", "train_id", "track_number", "created_by_id")
+	if err != nil {
+		return nil, err
+	}
+	if value := c.Query("track_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, errors.New("неверное значение параметра track_id")
+		}
+		query = query.Where("(departure_track_id = ? OR arrival_track_id = ?)", id, id)
+	}
+	if value := c.Query("station_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, errors.New("неверное значение параметра station_id")
+		}
+		query = query.Where("(from_station_id = ? OR to_station_id = ?)", id, id)
+	}
+	if statuses := queryList(c, "status"); len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
+
+	return query, nil
 }
 
 func GetSchedules(c *gin.Context) {
+	query, err := filterSchedules(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	p, err := paginate(c, query, &models.Schedule{}, scheduleListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var schedules []models.Schedule
-	database.DB.Preload("Train").Preload("FromStation").Preload("ToStation").Preload("CreatedBy").Find(&schedules)
+	p.query.Preload("Train").Preload("FromStation").Preload("ToStation").Preload("DepartureTrack").Preload("ArrivalTrack").Preload("CreatedBy").Preload("Stops", preloadStops).Find(&schedules)
+	p.finish(c, &schedules)
 	c.JSON(http.StatusOK, schedules)
 }
 
 func GetSchedule(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	var schedule models.Schedule
-	if err := database.DB.Preload("Train").Preload("FromStation").Preload("ToStation").Preload("CreatedBy").First(&schedule, id).Error; err != nil {
+	if err := database.DB.Preload("Train").Preload("FromStation").Preload("ToStation").Preload("DepartureTrack").Preload("ArrivalTrack").Preload("CreatedBy").
+		Preload("Stops", preloadStops).Preload("Stops.Station").Preload("Stops.Track").First(&schedule, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Рейс не найден"})
 		return
 	}
+	setETag(c, schedule.Version)
 	c.JSON(http.StatusOK, schedule)
 }
 
@@ -56,15 +157,18 @@ func CreateSchedule(c *gin.Context) {
 	uid := userID.(uint)
 
 	schedule := models.Schedule{
-		TrainID:       req.TrainID,
-		TrackNumber:   req.TrackNumber,
-		DepartureTime: req.DepartureTime,
-		ArrivalTime:   req.ArrivalTime,
-		Status:        req.Status,
-		Recurrence:    req.Recurrence,
-		FromStationID: req.FromStationID,
-		ToStationID:   req.ToStationID,
-		CreatedByID:   &uid,
+		TrainID:          req.TrainID,
+		TrackNumber:      req.TrackNumber,
+		DepartureTrackID: req.DepartureTrackID,
+		ArrivalTrackID:   req.ArrivalTrackID,
+		DepartureTime:    req.DepartureTime,
+		ArrivalTime:      req.ArrivalTime,
+		Status:           req.Status,
+		Recurrence:       req.Recurrence,
+		FromStationID:    req.FromStationID,
+		ToStationID:      req.ToStationID,
+		CreatedByID:      &uid,
+		Stops:            buildRouteStops(req.Stops),
 	}
 
 	if schedule.Status == "" {
@@ -74,34 +178,160 @@ func CreateSchedule(c *gin.Context) {
 		schedule.Recurrence = models.RecurrenceNone
 	}
 
-	if err := validator.ValidateSchedule(&schedule); err != nil {
-		duration := schedule.ArrivalTime.Sub(schedule.DepartureTime)
-		alternatives := validator.FindAlternativeSlots(schedule.TrackNumber, duration, schedule.DepartureTime)
-		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "alternatives": alternatives})
+	if req.RRule != "" {
+		schedule.RRule = req.RRule
+		schedule.Recurrence = models.RecurrenceRRule
+	} else {
+		rule, err := services.LegacyRRule(schedule.Recurrence, req.RecurCount)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		schedule.RRule = rule
+	}
+	if schedule.RRule != "" {
+		schedule.ExDates = strings.Join(req.ExDates, ",")
+	}
+
+	if r := checkSchedule(&schedule, true); r != nil {
+		c.JSON(r.status, r.body)
 		return
 	}
 
-	if err := physicsValidator.ValidateTravelPhysics(&schedule); err != nil {
+	occurrences, err := services.ExpandRecurrence(&schedule)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	reports, hasConflicts := validator.ValidateOccurrences(&schedule, occurrences)
 
-	if err := database.DB.Create(&schedule).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания рейса"})
+	// Пробный запуск возвращает результат проверки повторений без сохранения
+	if c.Query("dry_run") == "true" {
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "schedule": schedule, "occurrences": reports, "has_conflicts": hasConflicts})
 		return
 	}
 
-	if schedule.Recurrence != models.RecurrenceNone && req.RecurCount > 0 {
-		recurring, _ := services.GenerateRecurringSchedules(&schedule, req.RecurCount)
-		for _, s := range recurring {
-			database.DB.Create(&s)
+	if hasConflicts && req.OnConflict != "skip" {
+		c.JSON(http.StatusConflict, gin.H{"error": "коллизии в повторениях рейса", "occurrences": reports})
+		return
+	}
+
+	// Исходный рейс и все повторения создаются атомарно
+	booked := []*models.Schedule{&schedule}
+	for i := range occurrences {
+		if reports[i].Error == "" {
+			booked = append(booked, &occurrences[i])
 		}
 	}
+	err = validator.BookSchedules(booked, func(tx *gorm.DB) error {
+		if err := tx.Create(&schedule).Error; err != nil {
+			return err
+		}
+		for i := range occurrences {
+			if reports[i].Error != "" {
+				continue
+			}
+			occurrences[i].ParentID = &schedule.ID
+			if err := tx.Create(&occurrences[i]).Error; err != nil {
+				return err
+			}
+			reports[i].ScheduleID = occurrences[i].ID
+			reports[i].Created = true
+		}
+		return nil
+	})
+	if err != nil {
+		respondBookingError(c, err, "Ошибка создания рейса")
+		return
+	}
 
 	middleware.CreateAuditLog(c, models.ActionCreate, models.EntitySchedule, schedule.ID, nil, schedule)
+	if schedule.RRule != "" {
+		c.JSON(http.StatusCreated, gin.H{"schedule": schedule, "occurrences": reports})
+		return
+	}
 	c.JSON(http.StatusCreated, schedule)
 }
 
+// scheduleRejection — отказ в сохранении рейса с кодом и телом ответа.
+type scheduleRejection struct {
+	status int
+	body   gin.H
+}
+
+// checkSchedule выполняет все проверки рейса перед сохранением. continuity
+// включает проверку непрерывности маршрута поезда, которая не применяется к
+// повторениям серии: они идут по одному маршруту и стыкуются только по времени.
+func checkSchedule(schedule *models.Schedule, continuity bool) *scheduleRejection {
+	return checkScheduleExcluding(schedule, continuity, nil)
+}
+
+// checkScheduleExcluding — checkSchedule без учёта рейсов exclude при проверке
+// коллизий.
+func checkScheduleExcluding(schedule *models.Schedule, continuity bool, exclude []uint) *scheduleRejection {
+	if err := services.ResolveTracks(schedule); err != nil {
+		return &scheduleRejection{http.StatusBadRequest, gin.H{"error": err.Error()}}
+	}
+
+	if err := validator.ValidateStops(schedule); err != nil {
+		return &scheduleRejection{http.StatusBadRequest, gin.H{"error": err.Error()}}
+	}
+
+	if err := validator.ValidateTrackCapacity(schedule); err != nil {
+		return trackConflict(schedule, err)
+	}
+
+	if err := validator.Excluding(exclude).ValidateSchedule(schedule); err != nil {
+		return trackConflict(schedule, err)
+	}
+
+	trains := trainValidator.Excluding(exclude)
+	trainCheck := trains.ValidateTrainOverlap
+	if continuity {
+		trainCheck = trains.ValidateTrainAvailability
+	}
+	if err := trainCheck(schedule); err != nil {
+		return &scheduleRejection{http.StatusConflict, gin.H{"error": err.Error()}}
+	}
+
+	if err := physicsValidator.ValidateTravelPhysics(schedule); err != nil {
+		return physicsRejection(err)
+	}
+
+	return nil
+}
+
+func trackConflict(schedule *models.Schedule, err error) *scheduleRejection {
+	duration := schedule.ArrivalTime.Sub(schedule.DepartureTime)
+	alternatives := validator.FindAlternativeSlots(schedule, duration, schedule.DepartureTime)
+	return &scheduleRejection{http.StatusConflict, gin.H{"error": err.Error(), "alternatives": alternatives}}
+}
+
+// respondBookingError отвечает 409, если при сохранении под блокировкой
+// обнаружилась коллизия с рейсом, созданным параллельным запросом.
+func respondBookingError(c *gin.Context, err error, message string) {
+	var conflict *services.BookingConflictError
+	if errors.As(err, &conflict) {
+		r := trackConflict(conflict.Schedule, conflict.Err)
+		c.JSON(r.status, r.body)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
+
+func physicsRejection(err error) *scheduleRejection {
+	var physicsErr *services.PhysicsError
+	if errors.As(err, &physicsErr) {
+		return &scheduleRejection{http.StatusBadRequest, gin.H{
+			"error":                err.Error(),
+			"distance_km":          physicsErr.DistanceKm,
+			"speed_kmh":            physicsErr.SpeedKmh,
+			"min_duration_minutes": int(math.Ceil(physicsErr.MinDuration.Minutes())),
+		}}
+	}
+	return &scheduleRejection{http.StatusBadRequest, gin.H{"error": err.Error()}}
+}
+
 func canModifySchedule(c *gin.Context, schedule *models.Schedule) bool {
 	userID, _ := c.Get("userID")
 	userRole, _ := c.Get("userRole")
@@ -128,10 +358,26 @@ func canModifySchedule(c *gin.Context, schedule *models.Schedule) bool {
 }
 
 func UpdateSchedule(c *gin.Context) {
+	updateSchedule(c, false)
+}
+
+// PatchSchedule изменяет только переданные поля рейса (JSON Merge Patch).
+// Остановки заменяются целиком, если переданы, иначе сдвигаются вместе с рейсом.
+func PatchSchedule(c *gin.Context) {
+	updateSchedule(c, true)
+}
+
+func updateSchedule(c *gin.Context, patch bool) {
 	id, _ := strconv.Atoi(c.Param("id"))
 
+	scope, err := services.ParseSeriesScope(c.Query("scope"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var schedule models.Schedule
-	if err := database.DB.First(&schedule, id).Error; err != nil {
+	if err := database.DB.Preload("Stops", preloadStops).First(&schedule, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Рейс не найден"})
 		return
 	}
@@ -141,48 +387,143 @@ func UpdateSchedule(c *gin.Context) {
 		return
 	}
 
-	oldSchedule := schedule
+	if !checkIfMatch(c, schedule.Version, schedule) {
+		return
+	}
 
 	var req CreateScheduleRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+	if patch {
+		req = CreateScheduleRequest{
+			TrainID:          schedule.TrainID,
+			TrackNumber:      schedule.TrackNumber,
+			DepartureTrackID: schedule.DepartureTrackID,
+			ArrivalTrackID:   schedule.ArrivalTrackID,
+			DepartureTime:    schedule.DepartureTime,
+			ArrivalTime:      schedule.ArrivalTime,
+			FromStationID:    schedule.FromStationID,
+			ToStationID:      schedule.ToStationID,
+		}
+	}
+	if !bindRequest(c, &req, patch) {
+		return
+	}
+	// Остальные статусы рассчитываются по времени рейса и при чтении
+	// перезаписываются, поэтому вручную рейс можно только отменить
+	if req.Status != "" && req.Status != models.StatusCancelled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Статус рейса рассчитывается по времени, вручную его можно только отменить"})
 		return
 	}
 
-	schedule.TrainID = req.TrainID
-	schedule.TrackNumber = req.TrackNumber
-	schedule.DepartureTime = req.DepartureTime
-	schedule.ArrivalTime = req.ArrivalTime
-	schedule.FromStationID = req.FromStationID
-	schedule.ToStationID = req.ToStationID
-	if req.Status != "" {
-		schedule.Status = req.Status
+	members, err := services.SeriesMembers(&schedule, scope)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка загрузки серии рейсов"})
+		return
 	}
-	if req.Recurrence != "" {
-		schedule.Recurrence = req.Recurrence
+
+	// Сдвиг выбранного рейса переносится на остальные рейсы серии
+	shift := req.DepartureTime.Sub(schedule.DepartureTime)
+
+	members = editableMembers(members, schedule.ID)
+	oldMembers := make([]models.Schedule, len(members))
+	copy(oldMembers, members)
+
+	// Рейсы серии переносятся вместе, поэтому в базе друг другу не мешают;
+	// на новом времени они проверяются между собой отдельно
+	var exclude []uint
+	if scope != services.ScopeThis {
+		exclude = make([]uint, len(members))
+		for i := range members {
+			exclude[i] = members[i].ID
+		}
 	}
 
-	if err := validator.ValidateSchedule(&schedule); err != nil {
-		duration := schedule.ArrivalTime.Sub(schedule.DepartureTime)
-		alternatives := validator.FindAlternativeSlots(schedule.TrackNumber, duration, schedule.DepartureTime)
-		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "alternatives": alternatives})
+	for i := range members {
+		member := &members[i]
+		if !canModifySchedule(c, member) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав"})
+			return
+		}
+
+		applyScheduleRequest(member, &req, member.DepartureTime.Add(shift))
+		if member.ID == schedule.ID {
+			if req.Status != "" {
+				member.Status = req.Status
+			}
+			if req.Recurrence != "" {
+				member.Recurrence = req.Recurrence
+			}
+		}
+
+		if r := checkScheduleExcluding(member, scope == services.ScopeThis, exclude); r != nil {
+			if scope != services.ScopeThis {
+				r.body["schedule_id"] = member.ID
+			}
+			c.JSON(r.status, r.body)
+			return
+		}
+	}
+
+	if conflict, err := services.ValidateSeriesMembers(members); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "schedule_id": conflict.ID})
 		return
 	}
 
-	if err := physicsValidator.ValidateTravelPhysics(&schedule); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	booked := make([]*models.Schedule, len(members))
+	for i := range members {
+		booked[i] = &members[i]
+	}
+	err = validator.BookSchedules(booked, func(tx *gorm.DB) error {
+		for i := range members {
+			member := &members[i]
+			if err := saveVersioned(tx, member, &member.Version); err != nil {
+				return err
+			}
+
+			// Остановки пересоздаются: их времена сдвигаются вместе с рейсом
+			if err := tx.Where("schedule_id = ?", member.ID).Delete(&models.RouteStop{}).Error; err != nil {
+				return err
+			}
+			for j := range member.Stops {
+				member.Stops[j].ID = 0
+				member.Stops[j].ScheduleID = member.ID
+			}
+			if len(member.Stops) > 0 {
+				if err := tx.Create(&member.Stops).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errStaleVersion) {
+		respondVersionConflict(c, &schedule, schedule.ID)
+		return
+	}
+	if err != nil {
+		respondBookingError(c, err, "Ошибка сохранения рейса")
 		return
 	}
 
-	database.DB.Save(&schedule)
-	middleware.CreateAuditLog(c, models.ActionUpdate, models.EntitySchedule, schedule.ID, oldSchedule, schedule)
+	if scope == services.ScopeThis {
+		middleware.CreateAuditLog(c, models.ActionUpdate, models.EntitySchedule, schedule.ID, oldMembers[0], members[0])
+		setETag(c, members[0].Version)
+		c.JSON(http.StatusOK, members[0])
+		return
+	}
 
-	c.JSON(http.StatusOK, schedule)
+	auditSeries(c, models.ActionUpdate, &schedule, scope, oldMembers, members)
+	c.JSON(http.StatusOK, members)
 }
 
 func DeleteSchedule(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 
+	scope, err := services.ParseSeriesScope(c.Query("scope"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var schedule models.Schedule
 	if err := database.DB.First(&schedule, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Рейс не найден"})
@@ -194,37 +535,112 @@ func DeleteSchedule(c *gin.Context) {
 		return
 	}
 
-	database.DB.Delete(&schedule)
-	middleware.CreateAuditLog(c, models.ActionDelete, models.EntitySchedule, schedule.ID, schedule, nil)
+	if scope == services.ScopeThis {
+		database.DB.Delete(&schedule)
+		middleware.CreateAuditLog(c, models.ActionDelete, models.EntitySchedule, schedule.ID, schedule, nil)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Рейс удалён"})
+		return
+	}
+
+	members, err := services.SeriesMembers(&schedule, scope)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка загрузки серии рейсов"})
+		return
+	}
+	// Отправившиеся и выполненные рейсы серии остаются в истории
+	members = editableMembers(members, schedule.ID)
+
+	ids := make([]uint, 0, len(members))
+	for i := range members {
+		if !canModifySchedule(c, &members[i]) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав"})
+			return
+		}
+		ids = append(ids, members[i].ID)
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Рейс удалён"})
+	if err := database.DB.Delete(&models.Schedule{}, ids).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка удаления рейсов"})
+		return
+	}
+	auditSeries(c, models.ActionDelete, &schedule, scope, members, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Рейсы удалены", "deleted": ids})
 }
 
 func GetStats(c *gin.Context) {
 	var totalTrains int64
 	var activeSchedules int64
 	var totalTracks int64
+	var tracksAvailable int64
 	var totalStations int64
 
+	active := []string{"Scheduled", "InProgress"}
+
 	database.DB.Model(&models.Train{}).Count(&totalTrains)
-	database.DB.Model(&models.Schedule{}).Where("status IN ?", []string{"Scheduled", "InProgress"}).Count(&activeSchedules)
-	database.DB.Model(&models.Schedule{}).Distinct("track_number").Count(&totalTracks)
+	database.DB.Model(&models.Schedule{}).Where("status IN ?", active).Count(&activeSchedules)
+	database.DB.Raw(`SELECT COUNT(DISTINCT track_id) FROM (
+		SELECT departure_track_id AS track_id FROM schedules WHERE deleted_at IS NULL AND status IN ?
+		UNION
+		SELECT arrival_track_id AS track_id FROM schedules WHERE deleted_at IS NULL AND status IN ?
+	) AS used WHERE track_id IS NOT NULL`, active, active).Scan(&totalTracks)
+	database.DB.Model(&models.Track{}).Count(&tracksAvailable)
 	database.DB.Model(&models.Station{}).Count(&totalStations)
 
-	const totalTracksAvailable = 10
-	occupancy := float64(totalTracks) / float64(totalTracksAvailable) * 100
+	var occupancy float64
+	if tracksAvailable > 0 {
+		occupancy = float64(totalTracks) / float64(tracksAvailable) * 100
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"total_trains":      totalTrains,
 		"active_schedules":  activeSchedules,
 		"tracks_in_use":     totalTracks,
+		"total_tracks":      tracksAvailable,
 		"total_stations":    totalStations,
 		"occupancy_percent": occupancy,
 	})
 }
 
+var auditListSpec = listSpec{
+	sorts: map[string]sortField{
+		"id":        {"id", sortInt},
+		"timestamp": {"timestamp", sortTime},
+	},
+	defaultSort:  "timestamp",
+	defaultDesc:  true,
+	defaultLimit: 100,
+}
+
 func GetAuditLogs(c *gin.Context) {
+	query := database.DB.Model(&models.AuditLog{})
+
+	if actions := queryList(c, "action"); len(actions) > 0 {
+		query = query.Where("action IN ?", actions)
+	}
+	if entities := queryList(c, "entity"); len(entities) > 0 {
+		query = query.Where("entity IN ?", entities)
+	}
+	query, err := filterIDs(c, query, "user_id", "entity_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query, err = filterTimeRange(c, query, "timestamp")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	p, err := paginate(c, query, &models.AuditLog{}, auditListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var logs []models.AuditLog
-	database.DB.Preload("User").Order("timestamp DESC").Limit(100).Find(&logs)
+	p.query.Preload("User").Find(&logs)
+	p.finish(c, &logs)
 	c.JSON(http.StatusOK, logs)
 }
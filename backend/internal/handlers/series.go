@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/middleware"
+	"railway-dispatcher/internal/models"
+	"railway-dispatcher/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// applyScheduleRequest переносит поля запроса на рейс серии, отправляющийся в
+// departure: продолжительность рейса и времена остановок сохраняются
+// относительно отправления, прежние остановки сдвигаются вместе с рейсом.
+func applyScheduleRequest(schedule *models.Schedule, req *CreateScheduleRequest, departure time.Time) {
+	offset := departure.Sub(req.DepartureTime)
+	shift := departure.Sub(schedule.DepartureTime)
+
+	schedule.TrainID = req.TrainID
+	schedule.TrackNumber = req.TrackNumber
+	schedule.DepartureTrackID = req.DepartureTrackID
+	schedule.ArrivalTrackID = req.ArrivalTrackID
+	schedule.DepartureTime = departure
+	schedule.ArrivalTime = req.ArrivalTime.Add(offset)
+	schedule.FromStationID = req.FromStationID
+	schedule.ToStationID = req.ToStationID
+
+	if req.Stops != nil {
+		schedule.Stops = buildRouteStops(req.Stops)
+		for i := range schedule.Stops {
+			stop := &schedule.Stops[i]
+			stop.ScheduleID = schedule.ID
+			stop.ArrivalTime = stop.ArrivalTime.Add(offset)
+			if !stop.DepartureTime.IsZero() {
+				stop.DepartureTime = stop.DepartureTime.Add(offset)
+			}
+		}
+	} else {
+		// Копия, чтобы не изменить остановки исходного состояния рейса
+		schedule.Stops = append([]models.RouteStop(nil), schedule.Stops...)
+		for i := range schedule.Stops {
+			schedule.Stops[i].ArrivalTime = schedule.Stops[i].ArrivalTime.Add(shift)
+			schedule.Stops[i].DepartureTime = schedule.Stops[i].DepartureTime.Add(shift)
+		}
+	}
+}
+
+// editableMembers оставляет рейсы серии, которые ещё не отправились и не
+// отменены; выбранный рейс остаётся всегда.
+func editableMembers(members []models.Schedule, selectedID uint) []models.Schedule {
+	result := make([]models.Schedule, 0, len(members))
+	for _, m := range members {
+		if m.ID == selectedID || m.Status == models.StatusScheduled {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// auditSeries записывает изменение нескольких рейсов серии одной записью журнала.
+func auditSeries(c *gin.Context, action models.AuditAction, schedule *models.Schedule, scope services.SeriesScope, oldMembers, newMembers []models.Schedule) {
+	var newValue interface{}
+	if newMembers != nil {
+		newValue = gin.H{"scope": scope, "schedule_id": schedule.ID, "schedules": newMembers}
+	}
+	middleware.CreateAuditLog(c, action, models.EntityScheduleSeries, services.SeriesRootID(schedule),
+		gin.H{"scope": scope, "schedule_id": schedule.ID, "schedules": oldMembers},
+		newValue,
+	)
+}
+
+func GetScheduleSeries(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var schedule models.Schedule
+	if err := database.DB.First(&schedule, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Рейс не найден"})
+		return
+	}
+
+	rootID := services.SeriesRootID(&schedule)
+	var schedules []models.Schedule
+	database.DB.Preload("Train").Preload("FromStation").Preload("ToStation").Preload("DepartureTrack").Preload("ArrivalTrack").
+		Where("id = ? OR parent_id = ?", rootID, rootID).
+		Order("departure_time ASC").Find(&schedules)
+
+	c.JSON(http.StatusOK, gin.H{"root_id": rootID, "schedules": schedules})
+}
+
+func CancelSchedule(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	scope, err := services.ParseSeriesScope(c.Query("scope"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var schedule models.Schedule
+	if err := database.DB.First(&schedule, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Рейс не найден"})
+		return
+	}
+
+	if !canModifySchedule(c, &schedule) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав"})
+		return
+	}
+	if schedule.Status != models.StatusScheduled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Отменить можно только запланированный рейс"})
+		return
+	}
+
+	members, err := services.SeriesMembers(&schedule, scope)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка загрузки серии рейсов"})
+		return
+	}
+	members = editableMembers(members, schedule.ID)
+
+	ids := make([]uint, 0, len(members))
+	for i := range members {
+		if !canModifySchedule(c, &members[i]) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав"})
+			return
+		}
+		ids = append(ids, members[i].ID)
+	}
+
+	if err := database.DB.Model(&models.Schedule{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+		"status":  models.StatusCancelled,
+		"version": gorm.Expr("version + 1"),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка отмены рейсов"})
+		return
+	}
+
+	cancelled := make([]models.Schedule, len(members))
+	for i, m := range members {
+		m.Status = models.StatusCancelled
+		m.Version++
+		cancelled[i] = m
+	}
+
+	if scope == services.ScopeThis {
+		middleware.CreateAuditLog(c, models.ActionUpdate, models.EntitySchedule, schedule.ID, members[0], cancelled[0])
+	} else {
+		auditSeries(c, models.ActionUpdate, &schedule, scope, members, cancelled)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Рейсы отменены", "cancelled": ids})
+}
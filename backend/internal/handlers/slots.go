@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"railway-dispatcher/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var slotFinder = &services.SlotFinder{}
+
+// FindScheduleSlots ищет свободные слоты для рейса поезда около желаемого
+// времени отправления по всем подходящим путям станций.
+func FindScheduleSlots(c *gin.Context) {
+	trainID, err := strconv.ParseUint(c.Query("train_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не указан поезд"})
+		return
+	}
+
+	near, err := time.Parse(time.RFC3339, c.Query("departure"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат времени отправления"})
+		return
+	}
+
+	duration := time.Duration(queryInt(c, "duration", 0)) * time.Minute
+	if value := c.Query("arrival"); value != "" {
+		arrival, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат времени прибытия"})
+			return
+		}
+		duration = arrival.Sub(near)
+	}
+	if duration <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите продолжительность рейса или время прибытия"})
+		return
+	}
+
+	windowHours := queryInt(c, "window_hours", 24)
+	if windowHours < 1 || windowHours > 168 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Окно поиска — от 1 до 168 часов"})
+		return
+	}
+
+	limit := queryInt(c, "limit", 10)
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+
+	query := services.SlotQuery{
+		TrainID:     uint(trainID),
+		TrackNumber: queryInt(c, "track_number", 0),
+		Near:        near,
+		Duration:    duration,
+		Window:      time.Duration(windowHours) * time.Hour,
+		Limit:       limit,
+		ExcludeID:   uint(queryInt(c, "exclude_id", 0)),
+	}
+	for key, dest := range map[string]**uint{
+		"from":               &query.FromStationID,
+		"to":                 &query.ToStationID,
+		"departure_track_id": &query.DepartureTrackID,
+		"arrival_track_id":   &query.ArrivalTrackID,
+	} {
+		value := c.Query(key)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "неверное значение параметра " + key})
+			return
+		}
+		uid := uint(id)
+		*dest = &uid
+	}
+
+	slots, err := slotFinder.Find(query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if slots == nil {
+		slots = []services.TimeSlot{}
+	}
+	c.JSON(http.StatusOK, slots)
+}
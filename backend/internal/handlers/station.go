@@ -1,14 +1,17 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"railway-dispatcher/internal/database"
 	"railway-dispatcher/internal/middleware"
 	"railway-dispatcher/internal/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type CreateStationRequest struct {
@@ -17,22 +20,67 @@ type CreateStationRequest struct {
 	Type        models.StationType `json:"type"`
 	Latitude    float64            `json:"latitude"`
 	Longitude   float64            `json:"longitude"`
+	TimeZone    string             `json:"time_zone"`
 	Description string             `json:"description"`
 }
 
+func validateTimeZone(c *gin.Context, req *CreateStationRequest) bool {
+	if req.TimeZone == "" {
+		return true
+	}
+	if _, err := time.LoadLocation(req.TimeZone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неизвестный часовой пояс"})
+		return false
+	}
+	return true
+}
+
+var stationListSpec = listSpec{
+	sorts: map[string]sortField{
+		"id":         {"id", sortInt},
+		"name":       {"name", sortString},
+		"code":       {"code", sortString},
+		"type":       {"type", sortString},
+		"created_at": {"created_at", sortTime},
+	},
+	defaultSort: "id",
+}
+
 func GetStations(c *gin.Context) {
+	query, err := filterIDs(c, database.DB.Model(&models.Station{}), "created_by_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if types := queryList(c, "type"); len(types) > 0 {
+		query = query.Where("type IN ?", types)
+	}
+	if q := c.Query("q"); q != "" {
+		query = query.Where("name ILIKE ? OR code ILIKE ?", "%"+q+"%", "%"+q+"%")
+	}
+
+	p, err := paginate(c, query, &models.Station{}, stationListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var stations []models.Station
-	database.DB.Preload("CreatedBy").Find(&stations)
+	p.query.Preload("CreatedBy").Find(&stations)
+	p.finish(c, &stations)
 	c.JSON(http.StatusOK, stations)
 }
 
 func GetStation(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	var station models.Station
-	if err := database.DB.First(&station, id).Error; err != nil {
+	if err := database.DB.Preload("Tracks", func(db *gorm.DB) *gorm.DB {
+		return db.Order("number ASC")
+	}).First(&station, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Станция не найдена"})
 		return
 	}
+	setETag(c, station.Version)
 	c.JSON(http.StatusOK, station)
 }
 
@@ -52,6 +100,13 @@ func CreateStation(c *gin.Context) {
 		return
 	}
 
+	if !validateTimeZone(c, &req) {
+		return
+	}
+	if req.TimeZone == "" {
+		req.TimeZone = "Europe/Moscow"
+	}
+
 	if req.Type == "" {
 		req.Type = models.StationTypeRegular
 	}
@@ -63,6 +118,7 @@ func CreateStation(c *gin.Context) {
 		Type:        req.Type,
 		Latitude:    req.Latitude,
 		Longitude:   req.Longitude,
+		TimeZone:    req.TimeZone,
 		Description: req.Description,
 		CreatedByID: &uid,
 	}
@@ -92,6 +148,15 @@ func canModifyStation(c *gin.Context, station *models.Station) bool {
 }
 
 func UpdateStation(c *gin.Context) {
+	updateStation(c, false)
+}
+
+// PatchStation изменяет только переданные поля станции (JSON Merge Patch).
+func PatchStation(c *gin.Context) {
+	updateStation(c, true)
+}
+
+func updateStation(c *gin.Context, patch bool) {
 	id, _ := strconv.Atoi(c.Param("id"))
 
 	var station models.Station
@@ -105,11 +170,25 @@ func UpdateStation(c *gin.Context) {
 		return
 	}
 
+	if !checkIfMatch(c, station.Version, station) {
+		return
+	}
+
 	oldStation := station
 
 	var req CreateStationRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+	if patch {
+		req = CreateStationRequest{
+			Name:        station.Name,
+			Code:        station.Code,
+			Type:        station.Type,
+			Latitude:    station.Latitude,
+			Longitude:   station.Longitude,
+			TimeZone:    station.TimeZone,
+			Description: station.Description,
+		}
+	}
+	if !bindRequest(c, &req, patch) {
 		return
 	}
 
@@ -127,11 +206,25 @@ func UpdateStation(c *gin.Context) {
 	}
 	station.Latitude = req.Latitude
 	station.Longitude = req.Longitude
+	if req.TimeZone != "" {
+		if !validateTimeZone(c, &req) {
+			return
+		}
+		station.TimeZone = req.TimeZone
+	}
 	station.Description = req.Description
 
-	database.DB.Save(&station)
+	if err := saveVersioned(database.DB, &station, &station.Version); err != nil {
+		if errors.Is(err, errStaleVersion) {
+			respondVersionConflict(c, &station, station.ID)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения станции"})
+		return
+	}
 	middleware.CreateAuditLog(c, models.ActionUpdate, "Station", station.ID, oldStation, station)
 
+	setETag(c, station.Version)
 	c.JSON(http.StatusOK, station)
 }
 
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/middleware"
+	"railway-dispatcher/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateStationDistanceRequest struct {
+	FromStationID uint    `json:"from_station_id" binding:"required"`
+	ToStationID   uint    `json:"to_station_id" binding:"required"`
+	DistanceKm    float64 `json:"distance_km" binding:"required,gt=0"`
+	MaxSpeed      float64 `json:"max_speed" binding:"gte=0"`
+	Bidirectional *bool   `json:"bidirectional"`
+}
+
+func GetStationDistances(c *gin.Context) {
+	var distances []models.StationDistance
+	database.DB.Preload("FromStation").Preload("ToStation").Find(&distances)
+	c.JSON(http.StatusOK, distances)
+}
+
+func GetStationDistance(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var distance models.StationDistance
+	if err := database.DB.Preload("FromStation").Preload("ToStation").First(&distance, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Перегон не найден"})
+		return
+	}
+	c.JSON(http.StatusOK, distance)
+}
+
+func validateStationDistanceRequest(c *gin.Context, req *CreateStationDistanceRequest) bool {
+	if req.FromStationID == req.ToStationID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Станции отправления и назначения должны различаться"})
+		return false
+	}
+
+	var count int64
+	database.DB.Model(&models.Station{}).Where("id IN ?", []uint{req.FromStationID, req.ToStationID}).Count(&count)
+	if count != 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Станция не найдена"})
+		return false
+	}
+
+	return true
+}
+
+func CreateStationDistance(c *gin.Context) {
+	var req CreateStationDistanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+		return
+	}
+
+	if !validateStationDistanceRequest(c, &req) {
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	uid := userID.(uint)
+
+	distance := models.StationDistance{
+		FromStationID: req.FromStationID,
+		ToStationID:   req.ToStationID,
+		DistanceKm:    req.DistanceKm,
+		MaxSpeed:      req.MaxSpeed,
+		Bidirectional: true,
+		CreatedByID:   &uid,
+	}
+	if req.Bidirectional != nil {
+		distance.Bidirectional = *req.Bidirectional
+	}
+
+	if err := database.DB.Create(&distance).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Перегон между этими станциями уже существует"})
+		return
+	}
+
+	middleware.CreateAuditLog(c, models.ActionCreate, models.EntityStationDistance, distance.ID, nil, distance)
+	c.JSON(http.StatusCreated, distance)
+}
+
+func canModifyStationDistance(c *gin.Context, distance *models.StationDistance) bool {
+	userID, _ := c.Get("userID")
+	userRole, _ := c.Get("userRole")
+	uid := userID.(uint)
+	role := userRole.(models.Role)
+
+	if role == models.RoleAdmin {
+		return true
+	}
+	if distance.CreatedByID != nil && *distance.CreatedByID == uid {
+		return true
+	}
+	return false
+}
+
+func UpdateStationDistance(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var distance models.StationDistance
+	if err := database.DB.First(&distance, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Перегон не найден"})
+		return
+	}
+
+	if !canModifyStationDistance(c, &distance) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав"})
+		return
+	}
+
+	oldDistance := distance
+
+	var req CreateStationDistanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+		return
+	}
+
+	if !validateStationDistanceRequest(c, &req) {
+		return
+	}
+
+	distance.FromStationID = req.FromStationID
+	distance.ToStationID = req.ToStationID
+	distance.DistanceKm = req.DistanceKm
+	distance.MaxSpeed = req.MaxSpeed
+	if req.Bidirectional != nil {
+		distance.Bidirectional = *req.Bidirectional
+	}
+
+	if err := database.DB.Save(&distance).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Перегон между этими станциями уже существует"})
+		return
+	}
+	middleware.CreateAuditLog(c, models.ActionUpdate, models.EntityStationDistance, distance.ID, oldDistance, distance)
+
+	c.JSON(http.StatusOK, distance)
+}
+
+func DeleteStationDistance(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var distance models.StationDistance
+	if err := database.DB.First(&distance, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Перегон не найден"})
+		return
+	}
+
+	if !canModifyStationDistance(c, &distance) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав"})
+		return
+	}
+
+	database.DB.Delete(&distance)
+	middleware.CreateAuditLog(c, models.ActionDelete, models.EntityStationDistance, distance.ID, distance, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Перегон удалён"})
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/middleware"
+	"railway-dispatcher/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateTrackRequest struct {
+	StationID    uint   `json:"station_id" binding:"required"`
+	Number       int    `json:"number" binding:"required,gt=0"`
+	LengthWagons int    `json:"length_wagons" binding:"gte=0"`
+	Electrified  bool   `json:"electrified"`
+	Platform     bool   `json:"platform"`
+	Description  string `json:"description"`
+}
+
+func GetTracks(c *gin.Context) {
+	var tracks []models.Track
+	query := database.DB.Preload("Station").Order("station_id ASC, number ASC")
+	if stationID := c.Query("station_id"); stationID != "" {
+		query = query.Where("station_id = ?", stationID)
+	}
+	query.Find(&tracks)
+	c.JSON(http.StatusOK, tracks)
+}
+
+func GetTrack(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var track models.Track
+	if err := database.DB.Preload("Station").First(&track, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Путь не найден"})
+		return
+	}
+	c.JSON(http.StatusOK, track)
+}
+
+func loadTrackStation(c *gin.Context, stationID uint) (*models.Station, bool) {
+	var station models.Station
+	if err := database.DB.First(&station, stationID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Станция не найдена"})
+		return nil, false
+	}
+	if !canModifyStation(c, &station) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав"})
+		return nil, false
+	}
+
+	userRole, _ := c.Get("userRole")
+	if station.IsDepot() && userRole.(models.Role) != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Только Admin может редактировать депо"})
+		return nil, false
+	}
+	return &station, true
+}
+
+func CreateTrack(c *gin.Context) {
+	var req CreateTrackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+		return
+	}
+
+	if _, ok := loadTrackStation(c, req.StationID); !ok {
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	uid := userID.(uint)
+
+	track := models.Track{
+		StationID:    req.StationID,
+		Number:       req.Number,
+		LengthWagons: req.LengthWagons,
+		Electrified:  req.Electrified,
+		Platform:     req.Platform,
+		Description:  req.Description,
+		CreatedByID:  &uid,
+	}
+
+	if err := database.DB.Create(&track).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Путь с таким номером на станции уже существует"})
+		return
+	}
+
+	middleware.CreateAuditLog(c, models.ActionCreate, models.EntityTrack, track.ID, nil, track)
+	c.JSON(http.StatusCreated, track)
+}
+
+func UpdateTrack(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var track models.Track
+	if err := database.DB.First(&track, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Путь не найден"})
+		return
+	}
+
+	if _, ok := loadTrackStation(c, track.StationID); !ok {
+		return
+	}
+
+	oldTrack := track
+
+	var req CreateTrackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+		return
+	}
+
+	if req.StationID != track.StationID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя перенести путь на другую станцию"})
+		return
+	}
+
+	track.Number = req.Number
+	track.LengthWagons = req.LengthWagons
+	track.Electrified = req.Electrified
+	track.Platform = req.Platform
+	track.Description = req.Description
+
+	if err := database.DB.Save(&track).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Путь с таким номером на станции уже существует"})
+		return
+	}
+
+	database.DB.Model(&models.Schedule{}).Where("departure_track_id = ?", track.ID).Update("track_number", track.Number)
+	middleware.CreateAuditLog(c, models.ActionUpdate, models.EntityTrack, track.ID, oldTrack, track)
+
+	c.JSON(http.StatusOK, track)
+}
+
+func DeleteTrack(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var track models.Track
+	if err := database.DB.First(&track, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Путь не найден"})
+		return
+	}
+
+	if _, ok := loadTrackStation(c, track.StationID); !ok {
+		return
+	}
+
+	var used int64
+	database.DB.Model(&models.Schedule{}).
+		Where("(departure_track_id = ? OR arrival_track_id = ?) AND status IN ?", track.ID, track.ID, []string{"Scheduled", "InProgress"}).
+		Count(&used)
+	if used > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Путь используется в активных рейсах"})
+		return
+	}
+
+	database.DB.Delete(&track)
+	middleware.CreateAuditLog(c, models.ActionDelete, models.EntityTrack, track.ID, track, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Путь удалён"})
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/middleware"
+	"railway-dispatcher/internal/models"
+	"railway-dispatcher/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateTrackClosureRequest struct {
+	StationID uint      `json:"station_id" binding:"required"`
+	TrackID   *uint     `json:"track_id"`
+	StartTime time.Time `json:"start_time" binding:"required"`
+	EndTime   time.Time `json:"end_time" binding:"required"`
+	Reason    string    `json:"reason" binding:"required"`
+}
+
+func GetTrackClosures(c *gin.Context) {
+	query, err := filterIDs(c, database.DB.Model(&models.TrackClosure{}), "station_id", "track_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query, err = filterTimeRange(c, query, "start_time")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var closures []models.TrackClosure
+	query.Preload("Station").Preload("Track").Order("start_time ASC").Find(&closures)
+	c.JSON(http.StatusOK, closures)
+}
+
+func GetTrackClosure(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var closure models.TrackClosure
+	if err := database.DB.Preload("Station").Preload("Track").Preload("CreatedBy").First(&closure, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Закрытие не найдено"})
+		return
+	}
+	c.JSON(http.StatusOK, closure)
+}
+
+func validateTrackClosureRequest(c *gin.Context, req *CreateTrackClosureRequest) bool {
+	if !req.EndTime.After(req.StartTime) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Окончание закрытия должно быть позже начала"})
+		return false
+	}
+
+	var station models.Station
+	if err := database.DB.First(&station, req.StationID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Станция не найдена"})
+		return false
+	}
+
+	if req.TrackID != nil {
+		var track models.Track
+		if err := database.DB.First(&track, *req.TrackID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Путь не найден"})
+			return false
+		}
+		if track.StationID != req.StationID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Путь не принадлежит указанной станции"})
+			return false
+		}
+	}
+
+	return true
+}
+
+// CreateTrackClosure закрывает путь на время работ и возвращает рейсы,
+// которые нужно перенести.
+func CreateTrackClosure(c *gin.Context) {
+	var req CreateTrackClosureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+		return
+	}
+
+	if !validateTrackClosureRequest(c, &req) {
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	uid := userID.(uint)
+
+	closure := models.TrackClosure{
+		StationID:   req.StationID,
+		TrackID:     req.TrackID,
+		StartTime:   req.StartTime,
+		EndTime:     req.EndTime,
+		Reason:      req.Reason,
+		CreatedByID: &uid,
+	}
+
+	if err := database.DB.Create(&closure).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания закрытия"})
+		return
+	}
+	middleware.CreateAuditLog(c, models.ActionCreate, models.EntityTrackClosure, closure.ID, nil, closure)
+
+	// Закрытие уже сохранено, поэтому ошибка поиска затронутых рейсов
+	// возвращается вместе с ним, а не вместо него
+	impacted, err := services.ImpactedSchedules(&closure)
+	if err != nil {
+		c.JSON(http.StatusCreated, gin.H{"closure": closure, "impacted": nil, "impacted_error": "Ошибка поиска рейсов"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"closure": closure, "impacted": impacted})
+}
+
+func UpdateTrackClosure(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var closure models.TrackClosure
+	if err := database.DB.First(&closure, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Закрытие не найдено"})
+		return
+	}
+
+	oldClosure := closure
+
+	var req CreateTrackClosureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+		return
+	}
+
+	if !validateTrackClosureRequest(c, &req) {
+		return
+	}
+
+	closure.StationID = req.StationID
+	closure.TrackID = req.TrackID
+	closure.StartTime = req.StartTime
+	closure.EndTime = req.EndTime
+	closure.Reason = req.Reason
+
+	if err := database.DB.Save(&closure).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения закрытия"})
+		return
+	}
+	middleware.CreateAuditLog(c, models.ActionUpdate, models.EntityTrackClosure, closure.ID, oldClosure, closure)
+
+	c.JSON(http.StatusOK, closure)
+}
+
+func DeleteTrackClosure(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var closure models.TrackClosure
+	if err := database.DB.First(&closure, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Закрытие не найдено"})
+		return
+	}
+
+	database.DB.Delete(&closure)
+	middleware.CreateAuditLog(c, models.ActionDelete, models.EntityTrackClosure, closure.ID, closure, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Закрытие удалено"})
+}
+
+// GetClosureImpact возвращает рейсы, затронутые закрытием пути.
+func GetClosureImpact(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var closure models.TrackClosure
+	if err := database.DB.First(&closure, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Закрытие не найдено"})
+		return
+	}
+
+	impacted, err := services.ImpactedSchedules(&closure)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка поиска рейсов"})
+		return
+	}
+	c.JSON(http.StatusOK, impacted)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -19,9 +20,40 @@ type CreateTrainRequest struct {
 	Description string           `json:"description"`
 }
 
+var trainListSpec = listSpec{
+	sorts: map[string]sortField{
+		"id":          {"id", sortInt},
+		"number":      {"number", sortString},
+		"type":        {"type", sortString},
+		"wagon_count": {"wagon_count", sortInt},
+		"max_speed":   {"max_speed", sortFloat},
+		"created_at":  {"created_at", sortTime},
+	},
+	defaultSort: "id",
+}
+
 func GetTrains(c *gin.Context) {
+	query, err := filterIDs(c, database.DB.Model(&models.Train{}), "owner_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if types := queryList(c, "type"); len(types) > 0 {
+		query = query.Where("type IN ?", types)
+	}
+	if q := c.Query("q"); q != "" {
+		query = query.Where("number ILIKE ? OR description ILIKE ?", "%"+q+"%", "%"+q+"%")
+	}
+
+	p, err := paginate(c, query, &models.Train{}, trainListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var trains []models.Train
-	database.DB.Preload("Owner").Find(&trains)
+	p.query.Preload("Owner").Find(&trains)
+	p.finish(c, &trains)
 	c.JSON(http.StatusOK, trains)
 }
 
@@ -32,6 +64,7 @@ func GetTrain(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Поезд не найден"})
 		return
 	}
+	setETag(c, train.Version)
 	c.JSON(http.StatusOK, train)
 }
 
@@ -94,6 +127,15 @@ func canModifyTrain(c *gin.Context, train *models.Train) bool {
 }
 
 func UpdateTrain(c *gin.Context) {
+	updateTrain(c, false)
+}
+
+// PatchTrain изменяет только переданные поля поезда (JSON Merge Patch).
+func PatchTrain(c *gin.Context) {
+	updateTrain(c, true)
+}
+
+func updateTrain(c *gin.Context, patch bool) {
 	id, _ := strconv.Atoi(c.Param("id"))
 
 	var train models.Train
@@ -107,11 +149,23 @@ func UpdateTrain(c *gin.Context) {
 		return
 	}
 
+	if !checkIfMatch(c, train.Version, train) {
+		return
+	}
+
 	oldTrain := train
 
 	var req CreateTrainRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+	if patch {
+		req = CreateTrainRequest{
+			Number:      train.Number,
+			Type:        train.Type,
+			WagonCount:  train.WagonCount,
+			MaxSpeed:    train.MaxSpeed,
+			Description: train.Description,
+		}
+	}
+	if !bindRequest(c, &req, patch) {
 		return
 	}
 
@@ -127,9 +181,17 @@ func UpdateTrain(c *gin.Context) {
 	}
 	train.Description = req.Description
 
-	database.DB.Save(&train)
+	if err := saveVersioned(database.DB, &train, &train.Version); err != nil {
+		if errors.Is(err, errStaleVersion) {
+			respondVersionConflict(c, &train, train.ID)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения поезда"})
+		return
+	}
 	middleware.CreateAuditLog(c, models.ActionUpdate, models.EntityTrain, train.ID, oldTrain, train)
 
+	setETag(c, train.Version)
 	c.JSON(http.StatusOK, train)
 }
 
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/middleware"
+	"railway-dispatcher/internal/models"
+	"railway-dispatcher/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// currentUser загружает пользователя из токена запроса.
+func currentUser(c *gin.Context) (*models.User, bool) {
+	userID, _ := c.Get("userID")
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return nil, false
+	}
+	return &user, true
+}
+
+// LoginTwoFactor — второй шаг входа: проверяет код TOTP или код
+// восстановления по промежуточному токену и выдаёт токены доступа.
+func LoginTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	attempt, denial := loginLimiter.Begin(user.Login, c.ClientIP())
+	if denial != nil {
+		respondLoginDenied(c, denial)
+		return
+	}
+	defer attempt.Release()
+
+	usedRecovery, err := services.VerifyTwoFactor(user, req.Code)
+	if err != nil {
+		recordLoginFailure(c, attempt, user.Login, user.ID, true)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": services.ErrInvalidTwoFactorCode.Error()})
+		return
+	}
+	attempt.Succeed()
+
+	var extra gin.H
+	if usedRecovery {
+		middleware.CreateAuditLog(c, models.ActionUpdate, models.EntityUser, user.ID, nil, gin.H{"recovery_code_used": true})
+		extra = gin.H{"recovery_codes_left": services.RecoveryCodesLeft(user)}
+	}
+	issueLoginTokens(c, user, extra)
+}
+
+// SetupTwoFactor выдаёт новый секрет TOTP и otpauth URI для приложения.
+// Доступен после входа и по промежуточному токену обязательного подключения.
+func SetupTwoFactor(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	secret, uri, err := services.StartTwoFactorSetup(user)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"secret": secret, "uri": uri})
+}
+
+// EnableTwoFactor подтверждает подключение TOTP кодом и возвращает коды
+// восстановления. При обязательном подключении во время входа заодно выдаёт
+// токены доступа.
+func EnableTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	codes, err := services.EnableTwoFactor(user, req.Code)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidTwoFactorCode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	middleware.CreateAuditLog(c, models.ActionTwoFactorEnable, models.EntityUser, user.ID, nil, gin.H{"totp_enabled": true})
+
+	if _, preAuth := c.Get("preAuthPurpose"); preAuth {
+		loginLimiter.Succeed(user.Login)
+		issueLoginTokens(c, user, gin.H{"recovery_codes": codes})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTwoFactor отключает TOTP по паролю и текущему коду. Для ролей с
+// обязательной двухфакторной аутентификацией отключение запрещено.
+func DisableTwoFactor(c *gin.Context) {
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if services.TwoFactorRequired(user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Двухфакторная аутентификация обязательна для вашей роли"})
+		return
+	}
+	if !user.CheckPassword(req.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Неверный пароль"})
+		return
+	}
+	if _, err := services.VerifyTwoFactor(user, req.Code); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.DisableTwoFactor(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения пользователя"})
+		return
+	}
+	middleware.CreateAuditLog(c, models.ActionTwoFactorDisable, models.EntityUser, user.ID, nil, gin.H{"totp_enabled": false})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Двухфакторная аутентификация отключена"})
+}
+
+// RegenerateRecoveryCodes выдаёт новые коды восстановления взамен старых.
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if _, err := services.VerifyTwoFactor(user, req.Code); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := services.RegenerateRecoveryCodes(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения пользователя"})
+		return
+	}
+	middleware.CreateAuditLog(c, models.ActionUpdate, models.EntityUser, user.ID, nil, gin.H{"recovery_codes": "regenerated"})
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// ResetUserTwoFactor сбрасывает двухфакторную аутентификацию пользователя,
+// потерявшего устройство и коды восстановления, и отзывает его токены.
+// Если 2FA обязательна для роли, она будет подключена заново при входе.
+func ResetUserTwoFactor(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var user models.User
+	if err := database.DB.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+
+	wasEnabled := user.TOTPEnabled
+	if err := services.DisableTwoFactor(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения пользователя"})
+		return
+	}
+	services.RevokeUserTokens(database.DB, user.ID)
+	middleware.CreateAuditLog(c, models.ActionTwoFactorReset, models.EntityUser, user.ID,
+		gin.H{"totp_enabled": wasEnabled}, gin.H{"totp_enabled": false})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Двухфакторная аутентификация сброшена"})
+}
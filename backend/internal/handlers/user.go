@@ -1,19 +1,50 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"railway-dispatcher/internal/database"
 	"railway-dispatcher/internal/middleware"
 	"railway-dispatcher/internal/models"
+	"railway-dispatcher/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
+var userListSpec = listSpec{
+	sorts: map[string]sortField{
+		"id":         {"id", sortInt},
+		"login":      {"login", sortString},
+		"role":       {"role", sortString},
+		"created_at": {"created_at", sortTime},
+	},
+	defaultSort: "id",
+}
+
 func GetUsers(c *gin.Context) {
+	query := database.DB.Model(&models.User{})
+	if roles := queryList(c, "role"); len(roles) > 0 {
+		query = query.Where("role IN ?", roles)
+	}
+	if statuses := queryList(c, "status"); len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
+	if q := c.Query("q"); q != "" {
+		query = query.Where("login ILIKE ?", "%"+q+"%")
+	}
+
+	p, err := paginate(c, query, &models.User{}, userListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var users []models.User
-	database.DB.Find(&users)
+	p.query.Find(&users)
+	p.finish(c, &users)
 	c.JSON(http.StatusOK, users)
 }
 
@@ -26,6 +57,7 @@ func GetUser(c *gin.Context) {
 		return
 	}
 
+	setETag(c, user.Version)
 	c.JSON(http.StatusOK, user)
 }
 
@@ -44,6 +76,10 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
+	if !checkIfMatch(c, user.Version, user) {
+		return
+	}
+
 	oldUser := user
 
 	var req UpdateUserRequest
@@ -59,12 +95,30 @@ func UpdateUser(c *gin.Context) {
 		user.SetPassword(req.Password)
 	}
 	if req.Role != "" {
+		if !req.Role.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неизвестная роль"})
+			return
+		}
 		user.Role = req.Role
 	}
 
-	database.DB.Save(&user)
+	if err := saveVersioned(database.DB, &user, &user.Version); err != nil {
+		if errors.Is(err, errStaleVersion) {
+			respondVersionConflict(c, &user, user.ID)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения пользователя"})
+		return
+	}
+	// Выданные токены содержат старую роль и должны перестать действовать
+	if user.Role != oldUser.Role || req.Password != "" {
+		if services.RevokeUserTokens(database.DB, user.ID) == nil {
+			user.Version++
+		}
+	}
 	middleware.CreateAuditLog(c, models.ActionUpdate, models.EntityUser, user.ID, oldUser, user)
 
+	setETag(c, user.Version)
 	c.JSON(http.StatusOK, user)
 }
 
@@ -78,7 +132,97 @@ func DeleteUser(c *gin.Context) {
 	}
 
 	database.DB.Delete(&user)
+	services.RevokeUserTokens(database.DB, user.ID)
 	middleware.CreateAuditLog(c, models.ActionDelete, models.EntityUser, user.ID, user, nil)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Пользователь удалён"})
 }
+
+// ApproveUser подтверждает заявку на регистрацию. Администратор может заодно
+// изменить запрошенную роль полем role.
+func ApproveUser(c *gin.Context) {
+	reviewUser(c, true)
+}
+
+// RejectUser отклоняет заявку на регистрацию; вход для учётной записи закрыт.
+func RejectUser(c *gin.Context) {
+	reviewUser(c, false)
+}
+
+type ReviewUserRequest struct {
+	Role models.Role `json:"role"`
+}
+
+func reviewUser(c *gin.Context, approve bool) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var user models.User
+	if err := database.DB.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+	if user.Status != models.UserStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Пользователь не ожидает подтверждения"})
+		return
+	}
+
+	var req ReviewUserRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверные данные"})
+			return
+		}
+	}
+
+	oldUser := user
+	userID, _ := c.Get("userID")
+	adminID := userID.(uint)
+	now := time.Now()
+
+	action := models.ActionReject
+	user.Status = models.UserStatusRejected
+	if approve {
+		action = models.ActionApprove
+		user.Status = models.UserStatusActive
+		if req.Role != "" {
+			if !req.Role.Valid() {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Неизвестная роль"})
+				return
+			}
+			user.Role = req.Role
+		}
+	}
+	user.ApprovedByID = &adminID
+	user.ApprovedAt = &now
+
+	if err := saveVersioned(database.DB, &user, &user.Version); err != nil {
+		if errors.Is(err, errStaleVersion) {
+			respondVersionConflict(c, &user, user.ID)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения пользователя"})
+		return
+	}
+	middleware.CreateAuditLog(c, action, models.EntityUser, user.ID, oldUser, user)
+
+	setETag(c, user.Version)
+	c.JSON(http.StatusOK, user)
+}
+
+// UnlockUser снимает блокировку входа пользователя после неудачных попыток.
+// Параметр ?ip= дополнительно снимает блокировку с адреса.
+func UnlockUser(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var user models.User
+	if err := database.DB.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	}
+
+	ip := c.Query("ip")
+	loginLimiter.Unlock(user.Login, ip)
+	middleware.CreateAuditLog(c, models.ActionUnlock, models.EntityUser, user.ID, nil, gin.H{"login": user.Login, "ip": ip})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Блокировка входа снята"})
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"railway-dispatcher/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errStaleVersion = errors.New("версия записи устарела")
+
+func setETag(c *gin.Context, version uint) {
+	c.Header("ETag", strconv.Quote(strconv.FormatUint(uint64(version), 10)))
+}
+
+// checkIfMatch сравнивает заголовок If-Match с текущей версией записи и при
+// расхождении отвечает 409 с её текущим состоянием. Без заголовка проверка
+// выполняется только при сохранении.
+func checkIfMatch(c *gin.Context, version uint, current interface{}) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" || header == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if strings.Trim(tag, `"`) == strconv.FormatUint(uint64(version), 10) {
+			return true
+		}
+	}
+
+	setETag(c, version)
+	c.JSON(http.StatusConflict, gin.H{"error": "Запись была изменена другим пользователем", "current": current})
+	return false
+}
+
+// saveVersioned сохраняет все поля записи, только если её версия в базе не
+// изменилась с момента чтения, и увеличивает версию. Связанные записи не
+// сохраняются.
+func saveVersioned(db *gorm.DB, model interface{}, version *uint) error {
+	expected := *version
+	*version = expected + 1
+
+	result := db.Model(model).Where("version = ?", expected).Select("*").Omit(clause.Associations).Updates(model)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = errStaleVersion
+	}
+	if result.Error != nil {
+		*version = expected
+	}
+	return result.Error
+}
+
+// respondVersionConflict отвечает 409 с актуальным состоянием записи из базы.
+func respondVersionConflict(c *gin.Context, model interface{}, id uint) {
+	current := reflect.New(reflect.TypeOf(model).Elem()).Interface()
+	database.DB.First(current, id)
+	c.JSON(http.StatusConflict, gin.H{"error": "Запись была изменена другим пользователем", "current": current})
+}
@@ -1,8 +1,6 @@
 package middleware
 
 import (
-	"encoding/json"
-
 	"railway-dispatcher/internal/database"
 	"railway-dispatcher/internal/models"
 
@@ -14,44 +12,24 @@ func LogAudit(action models.AuditAction, entity models.AuditEntity, entityID uin
 		c.Next()
 
 		if c.Writer.Status() >= 200 && c.Writer.Status() < 300 {
-			userID, _ := c.Get("userID")
-
-			oldJSON, _ := json.Marshal(oldValue)
-			newJSON, _ := json.Marshal(newValue)
-
-			audit := models.AuditLog{
-				UserID:   userID.(uint),
-				Action:   action,
-				Entity:   entity,
-				EntityID: entityID,
-				OldValue: string(oldJSON),
-				NewValue: string(newJSON),
-				IP:       c.ClientIP(),
-			}
-
-			database.DB.Create(&audit)
+			CreateAuditLog(c, action, entity, entityID, oldValue, newValue)
 		}
 	}
 }
 
 func CreateAuditLog(c *gin.Context, action models.AuditAction, entity models.AuditEntity, entityID uint, oldVal, newVal interface{}) {
-	userID, exists := c.Get("userID")
-	if !exists {
-		userID = uint(0)
-	}
+	audit := models.NewAuditLog(action, entity, entityID, oldVal, newVal)
+	audit.UserID = auditUserID(c)
+	audit.IP = c.ClientIP()
 
-	oldJSON, _ := json.Marshal(oldVal)
-	newJSON, _ := json.Marshal(newVal)
+	database.DB.Create(audit)
+}
 
-	audit := models.AuditLog{
-		UserID:   userID.(uint),
-		Action:   action,
-		Entity:   entity,
-		EntityID: entityID,
-		OldValue: string(oldJSON),
-		NewValue: string(newJSON),
-		IP:       c.ClientIP(),
+func auditUserID(c *gin.Context) *uint {
+	userID, exists := c.Get("userID")
+	if !exists {
+		return nil
 	}
-
-	database.DB.Create(&audit)
+	uid := userID.(uint)
+	return &uid
 }
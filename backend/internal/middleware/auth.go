@@ -1,15 +1,28 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/models"
 	"railway-dispatcher/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
+	return tokenMiddleware("")
+}
+
+// PreAuthMiddleware принимает только промежуточный токен входа с назначением
+// purpose, выданный после проверки пароля.
+func PreAuthMiddleware(purpose string) gin.HandlerFunc {
+	return tokenMiddleware(purpose)
+}
+
+func tokenMiddleware(purpose string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -24,14 +37,29 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		claims, err := utils.ValidateToken(parts[1])
+		if err == nil && claims.Purpose != purpose {
+			err = errors.New("token purpose mismatch")
+		}
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Недействительный токен"})
 			return
 		}
 
+		// Токен отозван, если пользователь удалён, заблокирован или версия
+		// его токенов увеличилась после смены роли или пароля
+		var user models.User
+		if err := database.DB.Select("id", "token_version", "status").First(&user, claims.UserID).Error; err != nil ||
+			user.TokenVersion != claims.TokenVersion || !user.IsActive() {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Токен отозван"})
+			return
+		}
+
 		c.Set("userID", claims.UserID)
 		c.Set("userLogin", claims.Login)
 		c.Set("userRole", claims.Role)
+		if purpose != "" {
+			c.Set("preAuthPurpose", purpose)
+		}
 
 		c.Next()
 	}
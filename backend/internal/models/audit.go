@@ -1,26 +1,44 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type AuditAction string
 
 const (
-	ActionCreate AuditAction = "Create"
-	ActionUpdate AuditAction = "Update"
-	ActionDelete AuditAction = "Delete"
+	ActionCreate           AuditAction = "Create"
+	ActionUpdate           AuditAction = "Update"
+	ActionDelete           AuditAction = "Delete"
+	ActionStatus           AuditAction = "StatusChange"
+	ActionApprove          AuditAction = "Approve"
+	ActionReject           AuditAction = "Reject"
+	ActionLoginFailed      AuditAction = "LoginFailed"
+	ActionLockout          AuditAction = "Lockout" // Вход заблокирован после серии неудачных попыток
+	ActionUnlock           AuditAction = "Unlock"
+	ActionTwoFactorEnable  AuditAction = "TwoFactorEnable"
+	ActionTwoFactorDisable AuditAction = "TwoFactorDisable"
+	ActionTwoFactorReset   AuditAction = "TwoFactorReset" // Сброс 2FA пользователя администратором
 )
 
 type AuditEntity string
 
 const (
-	EntityUser     AuditEntity = "User"
-	EntityTrain    AuditEntity = "Train"
-	EntitySchedule AuditEntity = "Schedule"
+	EntityUser            AuditEntity = "User"
+	EntityTrain           AuditEntity = "Train"
+	EntitySchedule        AuditEntity = "Schedule"
+	EntityStationDistance AuditEntity = "StationDistance"
+	EntityTrack           AuditEntity = "Track"
+	EntityScheduleSeries  AuditEntity = "ScheduleSeries" // Изменение нескольких рейсов серии
+	EntityMaintenanceRule AuditEntity = "MaintenanceRule"
+	EntityTrackClosure    AuditEntity = "TrackClosure"
+	EntityScheduleImport  AuditEntity = "ScheduleImport" // Массовый импорт рейсов из CSV или GTFS
 )
 
 type AuditLog struct {
 	ID        uint        `gorm:"primaryKey" json:"id"`
-	UserID    uint        `gorm:"index" json:"user_id"`
+	UserID    *uint       `gorm:"index" json:"user_id"` // nil — действие сервера
 	Action    AuditAction `gorm:"not null" json:"action"`
 	Entity    AuditEntity `gorm:"not null" json:"entity"`
 	EntityID  uint        `json:"entity_id"`
@@ -29,5 +47,20 @@ type AuditLog struct {
 	IP        string      `json:"ip"`
 	Timestamp time.Time   `gorm:"autoCreateTime" json:"timestamp"`
 
-	User User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// NewAuditLog формирует запись журнала со значениями до и после изменения в
+// JSON. Пользователь и адрес заполняет вызывающий; без пользователя запись
+// относится к действию сервера.
+func NewAuditLog(action AuditAction, entity AuditEntity, entityID uint, oldVal, newVal interface{}) *AuditLog {
+	oldJSON, _ := json.Marshal(oldVal)
+	newJSON, _ := json.Marshal(newVal)
+	return &AuditLog{
+		Action:   action,
+		Entity:   entity,
+		EntityID: entityID,
+		OldValue: string(oldJSON),
+		NewValue: string(newJSON),
+	}
 }
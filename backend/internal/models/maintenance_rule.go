@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaintenanceRule задаёт минимальный интервал (тех. окно) между рейсами на пути.
+// Пустые поля означают «любой»; из подходящих правил действует самое точное.
+type MaintenanceRule struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	StationID     *uint          `gorm:"index" json:"station_id"`
+	TrackID       *uint          `gorm:"index" json:"track_id"`
+	TrainType     *TrainType     `json:"train_type"`              // Тип поезда рейса
+	PrevTrainType *TrainType     `json:"prev_train_type"`         // Тип поезда предыдущего рейса на пути
+	Minutes       int            `gorm:"not null" json:"minutes"` // Тех. окно, мин
+	Description   string         `json:"description"`
+	CreatedByID   *uint          `gorm:"index" json:"created_by_id"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Station   *Station `gorm:"foreignKey:StationID" json:"station,omitempty"`
+	Track     *Track   `gorm:"foreignKey:TrackID" json:"track,omitempty"`
+	CreatedBy *User    `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
+}
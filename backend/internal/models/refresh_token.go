@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// RefreshToken — выданный токен обновления. Хранится только хеш токена; при
+// каждом обновлении токен заменяется новым из того же семейства (FamilyID),
+// а повторное предъявление заменённого токена отзывает всё семейство.
+type RefreshToken struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash    string     `gorm:"uniqueIndex;not null" json:"-"`
+	FamilyID     string     `gorm:"not null;index" json:"family_id"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *uint      `json:"replaced_by_id"` // Токен, выданный взамен при обновлении
+	IP           string     `json:"ip"`
+	UserAgent    string     `json:"user_agent"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type RouteStop struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ScheduleID    uint      `gorm:"not null;index" json:"schedule_id"`
+	Sequence      int       `gorm:"not null" json:"sequence"` // Порядковый номер остановки в маршруте
+	StationID     uint      `gorm:"not null;index" json:"station_id"`
+	TrackID       *uint     `gorm:"index" json:"track_id"`
+	ArrivalTime   time.Time `gorm:"not null" json:"arrival_time"`
+	DepartureTime time.Time `gorm:"not null" json:"departure_time"`
+	DwellMinutes  int       `gorm:"not null;default:0" json:"dwell_minutes"` // Время стоянки, мин
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	Station *Station `gorm:"foreignKey:StationID" json:"station,omitempty"`
+	Track   *Track   `gorm:"foreignKey:TrackID" json:"track,omitempty"`
+}
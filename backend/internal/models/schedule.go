@@ -22,26 +22,73 @@ const (
 	RecurrenceDaily   Recurrence = "daily"
 	RecurrenceWeekly  Recurrence = "weekly"
 	RecurrenceMonthly Recurrence = "monthly"
+	RecurrenceRRule   Recurrence = "rrule" // Правило iCalendar в поле RRule
 )
 
 type Schedule struct {
-	ID            uint           `gorm:"primaryKey" json:"id"`
-	TrainID       uint           `gorm:"not null;index" json:"train_id"`
-	TrackNumber   int            `gorm:"not null" json:"track_number"`
-	DepartureTime time.Time      `gorm:"not null" json:"departure_time"`
-	ArrivalTime   time.Time      `gorm:"not null" json:"arrival_time"`
-	Status        ScheduleStatus `gorm:"not null;default:Scheduled" json:"status"`
-	Recurrence    Recurrence     `gorm:"not null;default:none" json:"recurrence"`
-	FromStationID *uint          `gorm:"index" json:"from_station_id"`
-	ToStationID   *uint          `gorm:"index" json:"to_station_id"`
-	ParentID      *uint          `gorm:"index" json:"parent_id"`
-	CreatedByID   *uint          `gorm:"index" json:"created_by_id"`
-	CreatedAt     time.Time      `json:"created_at"`
-	UpdatedAt     time.Time      `json:"updated_at"`
-	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
-
-	Train       Train    `gorm:"foreignKey:TrainID" json:"train,omitempty"`
-	FromStation *Station `gorm:"foreignKey:FromStationID" json:"from_station,omitempty"`
-	ToStation   *Station `gorm:"foreignKey:ToStationID" json:"to_station,omitempty"`
-	CreatedBy   *User    `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
+	ID                  uint           `gorm:"primaryKey" json:"id"`
+	TrainID             uint           `gorm:"not null;index" json:"train_id"`
+	TrackNumber         int            `gorm:"not null" json:"track_number"`
+	DepartureTrackID    *uint          `gorm:"index" json:"departure_track_id"` // Путь на станции отправления
+	ArrivalTrackID      *uint          `gorm:"index" json:"arrival_track_id"`   // Путь на станции прибытия
+	DepartureTime       time.Time      `gorm:"not null" json:"departure_time"`
+	ArrivalTime         time.Time      `gorm:"not null" json:"arrival_time"`
+	ActualDepartureTime *time.Time     `json:"actual_departure_time"`                   // Фактическое отправление
+	ActualArrivalTime   *time.Time     `json:"actual_arrival_time"`                     // Фактическое прибытие
+	DelayMinutes        int            `gorm:"not null;default:0" json:"delay_minutes"` // Ожидаемое опоздание, мин
+	Status              ScheduleStatus `gorm:"not null;default:Scheduled" json:"status"`
+	Recurrence          Recurrence     `gorm:"not null;default:none" json:"recurrence"`
+	RRule               string         `gorm:"type:text" json:"rrule,omitempty"`   // RRULE по RFC 5545
+	ExDates             string         `gorm:"type:text" json:"exdates,omitempty"` // Даты-исключения через запятую
+	FromStationID       *uint          `gorm:"index" json:"from_station_id"`
+	ToStationID         *uint          `gorm:"index" json:"to_station_id"`
+	ParentID            *uint          `gorm:"index" json:"parent_id"`
+	CreatedByID         *uint          `gorm:"index" json:"created_by_id"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	Version             uint           `gorm:"not null;default:1" json:"version"` // Версия записи для оптимистичной блокировки
+	DeletedAt           gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Train          Train       `gorm:"foreignKey:TrainID" json:"train,omitempty"`
+	FromStation    *Station    `gorm:"foreignKey:FromStationID" json:"from_station,omitempty"`
+	ToStation      *Station    `gorm:"foreignKey:ToStationID" json:"to_station,omitempty"`
+	DepartureTrack *Track      `gorm:"foreignKey:DepartureTrackID" json:"departure_track,omitempty"`
+	ArrivalTrack   *Track      `gorm:"foreignKey:ArrivalTrackID" json:"arrival_track,omitempty"`
+	CreatedBy      *User       `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
+	Stops          []RouteStop `gorm:"foreignKey:ScheduleID" json:"stops,omitempty"` // Промежуточные остановки
+}
+
+func (s *Schedule) ExpectedDepartureTime() time.Time {
+	if s.ActualDepartureTime != nil {
+		return *s.ActualDepartureTime
+	}
+	return s.DepartureTime.Add(time.Duration(s.DelayMinutes) * time.Minute)
+}
+
+func (s *Schedule) ExpectedArrivalTime() time.Time {
+	if s.ActualArrivalTime != nil {
+		return *s.ActualArrivalTime
+	}
+	return s.ArrivalTime.Add(time.Duration(s.DelayMinutes) * time.Minute)
+}
+
+// EffectiveStatus возвращает статус рейса с учётом текущего времени и опоздания.
+// Не меняется только отмена; остальные статусы выводятся из ожидаемых времён,
+// поэтому опоздание, сообщённое после планового прибытия, возвращает рейс в путь.
+func (s *Schedule) EffectiveStatus(now time.Time) ScheduleStatus {
+	switch {
+	case s.Status == StatusCancelled:
+		return s.Status
+	case s.ActualArrivalTime != nil || !now.Before(s.ExpectedArrivalTime()):
+		return StatusCompleted
+	case !now.Before(s.ExpectedDepartureTime()):
+		return StatusInProgress
+	default:
+		return StatusScheduled
+	}
+}
+
+func (s *Schedule) AfterFind(tx *gorm.DB) error {
+	s.Status = s.EffectiveStatus(time.Now())
+	return nil
 }
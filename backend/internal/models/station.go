@@ -20,13 +20,16 @@ type Station struct {
 	Type        StationType    `gorm:"not null;default:Regular" json:"type"`
 	Latitude    float64        `json:"latitude"`
 	Longitude   float64        `json:"longitude"`
+	TimeZone    string         `gorm:"not null;default:Europe/Moscow" json:"time_zone"` // Часовой пояс IANA
 	Description string         `json:"description"`
 	CreatedByID *uint          `gorm:"index" json:"created_by_id"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
+	Version     uint           `gorm:"not null;default:1" json:"version"` // Версия записи для оптимистичной блокировки
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 
-	CreatedBy *User `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
+	CreatedBy *User   `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
+	Tracks    []Track `gorm:"foreignKey:StationID" json:"tracks,omitempty"`
 }
 
 func (s *Station) IsDepot() bool {
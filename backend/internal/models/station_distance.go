@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type StationDistance struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	FromStationID uint           `gorm:"not null;uniqueIndex:idx_station_distance_active_pair,where:deleted_at IS NULL" json:"from_station_id"`
+	ToStationID   uint           `gorm:"not null;uniqueIndex:idx_station_distance_active_pair,where:deleted_at IS NULL" json:"to_station_id"`
+	DistanceKm    float64        `gorm:"not null" json:"distance_km"`         // Расстояние, км
+	MaxSpeed      float64        `gorm:"not null;default:0" json:"max_speed"` // Допустимая скорость на перегоне, км/ч (0 — без ограничения)
+	Bidirectional bool           `gorm:"not null" json:"bidirectional"`       // Перегон действует в обе стороны; по умолчанию true задаётся при создании
+	CreatedByID   *uint          `gorm:"index" json:"created_by_id"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+
+	FromStation *Station `gorm:"foreignKey:FromStationID" json:"from_station,omitempty"`
+	ToStation   *Station `gorm:"foreignKey:ToStationID" json:"to_station,omitempty"`
+	CreatedBy   *User    `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
+}
+
+func (d *StationDistance) Connects(fromID, toID uint) bool {
+	if d.FromStationID == fromID && d.ToStationID == toID {
+		return true
+	}
+	return d.Bidirectional && d.FromStationID == toID && d.ToStationID == fromID
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Track struct {
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	StationID    uint           `gorm:"not null;uniqueIndex:idx_track_station_number" json:"station_id"`
+	Number       int            `gorm:"not null;uniqueIndex:idx_track_station_number" json:"number"`
+	LengthWagons int            `gorm:"not null;default:0" json:"length_wagons"` // Вместимость пути в вагонах, 0 — не ограничена
+	Electrified  bool           `gorm:"not null;default:false" json:"electrified"`
+	Platform     bool           `gorm:"not null;default:false" json:"platform"` // Есть пассажирская платформа
+	Description  string         `json:"description"`
+	CreatedByID  *uint          `gorm:"index" json:"created_by_id"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Station   *Station `gorm:"foreignKey:StationID" json:"station,omitempty"`
+	CreatedBy *User    `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
+}
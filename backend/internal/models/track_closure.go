@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TrackClosure — закрытие пути (или всех путей станции) на время работ.
+type TrackClosure struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	StationID   uint           `gorm:"not null;index" json:"station_id"`
+	TrackID     *uint          `gorm:"index" json:"track_id"` // nil — закрыты все пути станции
+	StartTime   time.Time      `gorm:"not null;index" json:"start_time"`
+	EndTime     time.Time      `gorm:"not null;index" json:"end_time"`
+	Reason      string         `gorm:"not null" json:"reason"`
+	CreatedByID *uint          `gorm:"index" json:"created_by_id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Station   *Station `gorm:"foreignKey:StationID" json:"station,omitempty"`
+	Track     *Track   `gorm:"foreignKey:TrackID" json:"track,omitempty"`
+	CreatedBy *User    `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
+}
@@ -24,6 +24,7 @@ type Train struct {
 	Description string         `json:"description"`                           // Описание
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
+	Version     uint           `gorm:"not null;default:1" json:"version"` // Версия записи для оптимистичной блокировки
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 
 	Owner     *User      `gorm:"foreignKey:OwnerID" json:"owner,omitempty"`
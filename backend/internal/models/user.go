@@ -17,14 +17,46 @@ const (
 	RoleViewer     Role = "Viewer"     // Только просмотр (эквивалент Company)
 )
 
+// Valid сообщает, является ли значение одной из известных ролей.
+func (r Role) Valid() bool {
+	switch r {
+	case RoleAdmin, RoleCarrier, RoleCompany, RoleDispatcher, RoleViewer:
+		return true
+	}
+	return false
+}
+
+// RequiresApproval сообщает, нужна ли для роли при самостоятельной регистрации
+// проверка администратором.
+func (r Role) RequiresApproval() bool {
+	return r == RoleCarrier || r == RoleDispatcher
+}
+
+type UserStatus string
+
+const (
+	UserStatusActive   UserStatus = "Active"
+	UserStatusPending  UserStatus = "Pending"  // Ожидает подтверждения администратором
+	UserStatusRejected UserStatus = "Rejected" // Заявка на регистрацию отклонена
+)
+
 type User struct {
-	ID           uint           `gorm:"primaryKey" json:"id"`
-	Login        string         `gorm:"uniqueIndex;not null" json:"login"`
-	PasswordHash string         `gorm:"not null" json:"-"`
-	Role         Role           `gorm:"not null;default:Viewer" json:"role"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	Login         string         `gorm:"uniqueIndex;not null" json:"login"`
+	PasswordHash  string         `gorm:"not null" json:"-"`
+	Role          Role           `gorm:"not null;default:Viewer" json:"role"`
+	Status        UserStatus     `gorm:"not null;default:Active;index" json:"status"`
+	ApprovedByID  *uint          `json:"approved_by_id,omitempty"` // Администратор, подтвердивший регистрацию
+	ApprovedAt    *time.Time     `json:"approved_at,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	Version       uint           `gorm:"not null;default:1" json:"version"` // Версия записи для оптимистичной блокировки
+	TokenVersion  uint           `gorm:"not null;default:1" json:"-"`       // Увеличивается для отзыва всех выданных токенов
+	TOTPEnabled   bool           `gorm:"not null;default:false" json:"totp_enabled"`
+	TOTPSecret    string         `json:"-"`                           // Секрет TOTP в base32; до подтверждения TOTPEnabled = false
+	TOTPLastStep  int64          `gorm:"not null;default:0" json:"-"` // Шаг последнего принятого кода, защита от повторного ввода
+	RecoveryCodes string         `gorm:"type:text" json:"-"`          // SHA-256 неиспользованных кодов восстановления через запятую
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
 
 	Trains []Train `gorm:"foreignKey:OwnerID" json:"trains,omitempty"`
 }
@@ -43,6 +75,10 @@ func (u *User) CheckPassword(password string) bool {
 	return err == nil
 }
 
+func (u *User) IsActive() bool {
+	return u.Status == "" || u.Status == UserStatusActive
+}
+
 func (u *User) CanModify() bool {
 	return u.Role == RoleAdmin || u.Role == RoleCarrier || u.Role == RoleDispatcher
 }
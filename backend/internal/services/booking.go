@@ -0,0 +1,112 @@
+package services
+
+import (
+	"sort"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Классы ключей advisory-блокировок PostgreSQL, занимают старший байт ключа.
+const (
+	lockTrack int64 = iota + 1
+	lockLegacyTrack
+	lockTrain
+)
+
+// BookingConflictError — коллизия, обнаруженная при повторной проверке рейса
+// под блокировкой.
+type BookingConflictError struct {
+	Schedule *models.Schedule
+	Err      error
+}
+
+func (e *BookingConflictError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BookingConflictError) Unwrap() error {
+	return e.Err
+}
+
+func lockKey(class int64, value int64) int64 {
+	return class<<56 | value&(1<<56-1)
+}
+
+// bookingLockKeys возвращает отсортированные ключи блокировок всех путей и
+// поездов рейсов; сортировка исключает взаимоблокировку параллельных запросов.
+func bookingLockKeys(schedules []*models.Schedule) []int64 {
+	seen := make(map[int64]bool)
+	add := func(key int64) {
+		seen[key] = true
+	}
+
+	for _, s := range schedules {
+		add(lockKey(lockTrain, int64(s.TrainID)))
+		if s.DepartureTrackID != nil {
+			add(lockKey(lockTrack, int64(*s.DepartureTrackID)))
+		}
+		if s.ArrivalTrackID != nil {
+			add(lockKey(lockTrack, int64(*s.ArrivalTrackID)))
+		}
+		if s.DepartureTrackID == nil && s.ArrivalTrackID == nil {
+			var station int64
+			if s.FromStationID != nil {
+				station = int64(*s.FromStationID)
+			}
+			add(lockKey(lockLegacyTrack, station<<20|int64(s.TrackNumber)&(1<<20-1)))
+		}
+		for _, stop := range s.Stops {
+			if stop.TrackID != nil {
+				add(lockKey(lockTrack, int64(*stop.TrackID)))
+			}
+		}
+	}
+
+	keys := make([]int64, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// BookSchedules сохраняет рейсы атомарно с проверкой коллизий. В транзакции
+// берутся advisory-блокировки на пути и поезда рейсов, после чего коллизии
+// путей и занятость поездов проверяются повторно в той же транзакции:
+// параллельный запрос, забронировавший тот же слот, к этому моменту уже
+// зафиксирован и виден.
+// Проверки до блокировки остаются для подбора альтернатив, но решение
+// принимается только здесь. save выполняет запись в рамках той же транзакции.
+func (v *ScheduleValidator) BookSchedules(schedules []*models.Schedule, save func(tx *gorm.DB) error) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, key := range bookingLockKeys(schedules) {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", key).Error; err != nil {
+				return err
+			}
+		}
+
+		// Сохраняемые рейсы не мешают друг другу: при переносе серии остальные
+		// её рейсы ещё стоят в базе на прежнем времени
+		var ids []uint
+		for _, s := range schedules {
+			if s.ID != 0 {
+				ids = append(ids, s.ID)
+			}
+		}
+		locked := &ScheduleValidator{db: tx, exclude: ids}
+		trains := &TrainAvailabilityValidator{db: tx, exclude: ids}
+		for _, s := range schedules {
+			if err := locked.ValidateSchedule(s); err != nil {
+				return &BookingConflictError{Schedule: s, Err: err}
+			}
+			if err := trains.ValidateTrainOverlap(s); err != nil {
+				return &BookingConflictError{Schedule: s, Err: err}
+			}
+		}
+
+		return save(tx)
+	})
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ClosureError — рейс попадает на закрытый для работ путь.
+type ClosureError struct {
+	Closure models.TrackClosure
+}
+
+func (e *ClosureError) Error() string {
+	return fmt.Sprintf("путь закрыт на работы с %s по %s: %s",
+		e.Closure.StartTime.Format("02.01.2006 15:04"), e.Closure.EndTime.Format("02.01.2006 15:04"), e.Closure.Reason)
+}
+
+// scopeClosures возвращает закрытия, которые затрагивают путь области scope в
+// интервале [from, to). Закрытие всей станции действует на каждый её путь;
+// рейсы без привязки к путям сопоставляются по номеру пути станции отправления.
+func scopeClosures(db *gorm.DB, scope trackScope, from, to time.Time) []models.TrackClosure {
+	var query *gorm.DB
+	switch {
+	case scope.trackID != nil:
+		query = db.Where(
+			"track_id = ? OR (track_id IS NULL AND station_id = (SELECT station_id FROM tracks WHERE id = ?))",
+			*scope.trackID, *scope.trackID,
+		)
+	case scope.stationID != nil:
+		query = db.Where(
+			"station_id = ? AND (track_id IS NULL OR track_id IN (SELECT id FROM tracks WHERE station_id = ? AND number = ? AND deleted_at IS NULL))",
+			*scope.stationID, *scope.stationID, scope.trackNumber,
+		)
+	default:
+		return nil
+	}
+
+	var closures []models.TrackClosure
+	query.Where("start_time < ? AND end_time > ?", to, from).Order("start_time ASC").Find(&closures)
+	return closures
+}
+
+func validateClosures(db *gorm.DB, scope trackScope) error {
+	closures := scopeClosures(db, scope, scope.from, scope.to)
+	if len(closures) > 0 {
+		return &ClosureError{Closure: closures[0]}
+	}
+	return nil
+}
+
+// ImpactedSchedules возвращает предстоящие рейсы, которые занимают закрытые
+// пути во время закрытия: отправляются с них, прибывают на них или стоят на
+// них на промежуточных остановках.
+func ImpactedSchedules(closure *models.TrackClosure) ([]models.Schedule, error) {
+	trackIDs := database.DB.Model(&models.Track{}).Select("id").Where("station_id = ?", closure.StationID)
+	trackNumbers := database.DB.Model(&models.Track{}).Select("number").Where("station_id = ?", closure.StationID)
+	if closure.TrackID != nil {
+		trackIDs = trackIDs.Where("id = ?", *closure.TrackID)
+		trackNumbers = trackNumbers.Where("id = ?", *closure.TrackID)
+	}
+
+	stopSchedules := database.DB.Model(&models.RouteStop{}).Select("schedule_id").
+		Where("track_id IN (?) AND arrival_time < ? AND departure_time > ?", trackIDs, closure.EndTime, closure.StartTime)
+
+	legacy := database.DB.Where("from_station_id = ? AND departure_track_id IS NULL AND arrival_track_id IS NULL AND departure_time > ? AND departure_time < ?",
+		closure.StationID, closure.StartTime, closure.EndTime)
+	if closure.TrackID != nil {
+		legacy = legacy.Where("track_number IN (?)", trackNumbers)
+	}
+
+	var schedules []models.Schedule
+	err := database.DB.Preload("Train").Preload("FromStation").Preload("ToStation").
+		Where("status IN ?", []models.ScheduleStatus{models.StatusScheduled, models.StatusInProgress}).
+		Where(database.DB.
+			Where("departure_track_id IN (?) AND departure_time > ? AND departure_time < ?", trackIDs, closure.StartTime, closure.EndTime).
+			Or("arrival_track_id IN (?) AND arrival_time > ? AND arrival_time < ?", trackIDs, closure.StartTime, closure.EndTime).
+			Or(legacy).
+			Or("id IN (?)", stopSchedules)).
+		Order("departure_time ASC").Find(&schedules).Error
+	return schedules, err
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type DelayChange struct {
+	ScheduleID   uint `json:"schedule_id"`
+	OldDelay     int  `json:"old_delay_minutes"`
+	DelayMinutes int  `json:"delay_minutes"`
+}
+
+type DelayConflict struct {
+	ScheduleID uint   `json:"schedule_id"`
+	Error      string `json:"error"`
+}
+
+// PropagateDelay переносит ожидаемое опоздание на последующие рейсы того же поезда:
+// каждый следующий рейс не может отправиться раньше прибытия предыдущего плюс
+// время на оборот. Опоздание только увеличивается: сообщённое для самого рейса
+// не уменьшается, а распространение останавливается на рейсе, который уже
+// отправился или опоздание которого покрывает требуемое. Статус рейсов,
+// завершённых по плановому времени, пересчитывается по новому ожидаемому.
+// Изменения записываются через db — транзакцию сохранения исходного рейса.
+func PropagateDelay(db *gorm.DB, schedule *models.Schedule) ([]DelayChange, error) {
+	var following []models.Schedule
+	err := db.Where(
+		"train_id = ? AND id != ? AND departure_time > ? AND status != ? AND actual_departure_time IS NULL",
+		schedule.TrainID, schedule.ID, schedule.DepartureTime, models.StatusCancelled,
+	).Order("departure_time ASC").Find(&following).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []DelayChange
+	prevArrival := schedule.ExpectedArrivalTime()
+	for i := range following {
+		next := &following[i]
+
+		delay := int(math.Ceil(prevArrival.Add(TrainTurnaround).Sub(next.DepartureTime).Minutes()))
+		if delay <= next.DelayMinutes {
+			break
+		}
+
+		changes = append(changes, DelayChange{ScheduleID: next.ID, OldDelay: next.DelayMinutes, DelayMinutes: delay})
+		next.DelayMinutes = delay
+		next.Status = next.EffectiveStatus(time.Now())
+		if err := db.Model(next).Updates(map[string]interface{}{
+			"delay_minutes": delay,
+			"status":        next.Status,
+			"version":       gorm.Expr("version + 1"),
+		}).Error; err != nil {
+			return changes, err
+		}
+
+		prevArrival = next.ExpectedArrivalTime()
+	}
+
+	return changes, nil
+}
+
+// CheckDelayConflicts проверяет рейсы по ожидаемым с учётом опоздания временам
+// и возвращает возникшие коллизии путей, не отклоняя сами изменения.
+func (v *ScheduleValidator) CheckDelayConflicts(scheduleIDs []uint) []DelayConflict {
+	var conflicts []DelayConflict
+
+	for _, id := range scheduleIDs {
+		var schedule models.Schedule
+		if err := database.DB.First(&schedule, id).Error; err != nil {
+			continue
+		}
+
+		shifted := schedule
+		shifted.DepartureTime = schedule.ExpectedDepartureTime()
+		shifted.ArrivalTime = schedule.ExpectedArrivalTime()
+		if err := v.ValidateSchedule(&shifted); err != nil {
+			conflicts = append(conflicts, DelayConflict{ScheduleID: id, Error: err.Error()})
+		}
+	}
+
+	return conflicts
+}
+
+func delayMinutes(actual, planned time.Time) int {
+	return int(math.Round(actual.Sub(planned).Minutes()))
+}
+
+// ApplyActualTimes фиксирует фактические времена рейса и пересчитывает опоздание.
+func ApplyActualTimes(schedule *models.Schedule, departure, arrival *time.Time) {
+	if departure != nil {
+		schedule.ActualDepartureTime = departure
+		schedule.DelayMinutes = delayMinutes(*departure, schedule.DepartureTime)
+	}
+	if arrival != nil {
+		schedule.ActualArrivalTime = arrival
+		schedule.DelayMinutes = delayMinutes(*arrival, schedule.ArrivalTime)
+	}
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	MaxJourneyTransfers = 5
+	MaxJourneyResults   = 20
+)
+
+type JourneyQuery struct {
+	FromStationID uint
+	ToStationID   uint
+	After         time.Time     // Самое раннее время отправления
+	Window        time.Duration // Интервал поиска отправлений
+	MaxTransfers  int
+	MinTransfer   time.Duration // Минимальное время на пересадку
+	Limit         int
+}
+
+type JourneyLeg struct {
+	ScheduleID      uint      `json:"schedule_id"`
+	TrainID         uint      `json:"train_id"`
+	TrainNumber     string    `json:"train_number"`
+	FromStationID   uint      `json:"from_station_id"`
+	FromStationName string    `json:"from_station_name"`
+	ToStationID     uint      `json:"to_station_id"`
+	ToStationName   string    `json:"to_station_name"`
+	DepartureTime   time.Time `json:"departure_time"`
+	ArrivalTime     time.Time `json:"arrival_time"`
+	TransferMinutes int       `json:"transfer_minutes"` // Ожидание пересадки перед этим участком
+}
+
+type Journey struct {
+	DepartureTime   time.Time    `json:"departure_time"`
+	ArrivalTime     time.Time    `json:"arrival_time"`
+	DurationMinutes int          `json:"duration_minutes"`
+	Transfers       int          `json:"transfers"`
+	Legs            []JourneyLeg `json:"legs"`
+}
+
+// connection — элементарный перегон рейса между соседними остановками.
+type connection struct {
+	schedule      *models.Schedule
+	fromStationID uint
+	toStationID   uint
+	departure     time.Time
+	arrival       time.Time
+}
+
+type legPointer struct {
+	enter *connection
+	exit  *connection
+	prevK int // Число пересадок до посадки, -1 — посадка на станции отправления
+}
+
+type JourneyPlanner struct{}
+
+// Plan ищет маршруты алгоритмом Connection Scan: перегоны просматриваются
+// в порядке отправления, для каждого числа пересадок хранится самое раннее
+// прибытие на станцию. Поиск повторяется от следующего отправления, пока не
+// набрано нужное число вариантов.
+func (p *JourneyPlanner) Plan(q JourneyQuery) ([]Journey, error) {
+	if q.FromStationID == q.ToStationID {
+		return nil, errors.New("станции отправления и назначения совпадают")
+	}
+	if q.MaxTransfers < 0 {
+		q.MaxTransfers = 0
+	} else if q.MaxTransfers > MaxJourneyTransfers {
+		q.MaxTransfers = MaxJourneyTransfers
+	}
+	if q.Limit <= 0 || q.Limit > MaxJourneyResults {
+		q.Limit = MaxJourneyResults
+	}
+
+	// Пересадочные рейсы могут отправляться позже окна поиска
+	connections, err := loadConnections(q.After, q.After.Add(q.Window+24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+
+	stations := make(map[uint]string)
+	var stationList []models.Station
+	database.DB.Find(&stationList)
+	for _, s := range stationList {
+		stations[s.ID] = s.Name
+	}
+
+	var journeys []Journey
+	seen := make(map[string]bool)
+	start := q.After
+	end := q.After.Add(q.Window)
+
+	for len(journeys) < q.Limit && start.Before(end) {
+		found := scanConnections(connections, q, start, end)
+		if len(found) == 0 {
+			break
+		}
+
+		earliest := found[0].DepartureTime
+		for _, j := range found {
+			key := journeyKey(j)
+			if !seen[key] {
+				seen[key] = true
+				fillStationNames(&j, stations)
+				journeys = append(journeys, j)
+			}
+			if j.DepartureTime.Before(earliest) {
+				earliest = j.DepartureTime
+			}
+		}
+		start = earliest.Add(time.Minute)
+	}
+
+	sort.SliceStable(journeys, func(i, j int) bool {
+		if !journeys[i].ArrivalTime.Equal(journeys[j].ArrivalTime) {
+			return journeys[i].ArrivalTime.Before(journeys[j].ArrivalTime)
+		}
+		if journeys[i].Transfers != journeys[j].Transfers {
+			return journeys[i].Transfers < journeys[j].Transfers
+		}
+		return journeys[i].DurationMinutes < journeys[j].DurationMinutes
+	})
+	if len(journeys) > q.Limit {
+		journeys = journeys[:q.Limit]
+	}
+
+	return journeys, nil
+}
+
+func loadConnections(from, to time.Time) ([]connection, error) {
+	var schedules []models.Schedule
+	err := database.DB.Preload("Train").Preload("Stops", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sequence ASC")
+	}).Where(
+		"departure_time >= ? AND departure_time < ? AND status != ? AND from_station_id IS NOT NULL AND to_station_id IS NOT NULL",
+		from, to, models.StatusCancelled,
+	).Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var connections []connection
+	for i := range schedules {
+		s := &schedules[i]
+		fromID, departure := *s.FromStationID, s.DepartureTime
+		for _, stop := range s.Stops {
+			connections = append(connections, connection{s, fromID, stop.StationID, departure, stop.ArrivalTime})
+			fromID, departure = stop.StationID, stop.DepartureTime
+		}
+		connections = append(connections, connection{s, fromID, *s.ToStationID, departure, s.ArrivalTime})
+	}
+
+	sort.SliceStable(connections, func(i, j int) bool {
+		return connections[i].departure.Before(connections[j].departure)
+	})
+	return connections, nil
+}
+
+// scanConnections возвращает Парето-оптимальные по (прибытие, пересадки)
+// маршруты с отправлением в интервале [start, end).
+func scanConnections(connections []connection, q JourneyQuery, start, end time.Time) []Journey {
+	levels := q.MaxTransfers + 1
+	arrival := make([]map[uint]time.Time, levels)
+	pointers := make([]map[uint]legPointer, levels)
+	boarded := make([]map[uint]legPointer, levels)
+	for k := 0; k < levels; k++ {
+		arrival[k] = make(map[uint]time.Time)
+		pointers[k] = make(map[uint]legPointer)
+		boarded[k] = make(map[uint]legPointer)
+	}
+
+	for i := range connections {
+		c := &connections[i]
+		if c.departure.Before(start) {
+			continue
+		}
+
+		for k := 0; k < levels; k++ {
+			trip, onTrip := boarded[k][c.schedule.ID]
+			if !onTrip {
+				switch {
+				case c.fromStationID == q.FromStationID && c.departure.Before(end):
+					trip = legPointer{enter: c, prevK: -1}
+				case k > 0:
+					prev, ok := arrival[k-1][c.fromStationID]
+					if !ok || c.departure.Before(prev.Add(q.MinTransfer)) {
+						continue
+					}
+					trip = legPointer{enter: c, prevK: k - 1}
+				default:
+					continue
+				}
+				boarded[k][c.schedule.ID] = trip
+			}
+
+			if c.toStationID == q.FromStationID {
+				continue
+			}
+			if best, ok := arrival[k][c.toStationID]; !ok || c.arrival.Before(best) {
+				arrival[k][c.toStationID] = c.arrival
+				pointers[k][c.toStationID] = legPointer{enter: trip.enter, exit: c, prevK: trip.prevK}
+			}
+		}
+	}
+
+	var journeys []Journey
+	var bestArrival time.Time
+	for k := 0; k < levels; k++ {
+		arr, ok := arrival[k][q.ToStationID]
+		if !ok || (!bestArrival.IsZero() && !arr.Before(bestArrival)) {
+			continue
+		}
+		bestArrival = arr
+		journeys = append(journeys, buildJourney(pointers, k, q.ToStationID))
+	}
+	return journeys
+}
+
+func buildJourney(pointers []map[uint]legPointer, k int, target uint) Journey {
+	var legs []JourneyLeg
+	station := target
+	for k >= 0 {
+		ptr := pointers[k][station]
+		legs = append([]JourneyLeg{{
+			ScheduleID:    ptr.enter.schedule.ID,
+			TrainID:       ptr.enter.schedule.TrainID,
+			TrainNumber:   ptr.enter.schedule.Train.Number,
+			FromStationID: ptr.enter.fromStationID,
+			ToStationID:   ptr.exit.toStationID,
+			DepartureTime: ptr.enter.departure,
+			ArrivalTime:   ptr.exit.arrival,
+		}}, legs...)
+		station = ptr.enter.fromStationID
+		k = ptr.prevK
+	}
+
+	for i := 1; i < len(legs); i++ {
+		legs[i].TransferMinutes = int(legs[i].DepartureTime.Sub(legs[i-1].ArrivalTime).Minutes())
+	}
+
+	first, last := legs[0], legs[len(legs)-1]
+	return Journey{
+		DepartureTime:   first.DepartureTime,
+		ArrivalTime:     last.ArrivalTime,
+		DurationMinutes: int(last.ArrivalTime.Sub(first.DepartureTime).Minutes()),
+		Transfers:       len(legs) - 1,
+		Legs:            legs,
+	}
+}
+
+func journeyKey(j Journey) string {
+	var key strings.Builder
+	for _, leg := range j.Legs {
+		fmt.Fprintf(&key, "%d:%d:%d;", leg.ScheduleID, leg.FromStationID, leg.ToStationID)
+	}
+	return key.String()
+}
+
+func fillStationNames(j *Journey, stations map[uint]string) {
+	for i := range j.Legs {
+		j.Legs[i].FromStationName = stations[j.Legs[i].FromStationID]
+		j.Legs[i].ToStationName = stations[j.Legs[i].ToStationID]
+	}
+}
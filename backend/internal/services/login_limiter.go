@@ -0,0 +1,264 @@
+package services
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoginAttempts — счётчик неудачных попыток входа по логину или IP.
+type LoginAttempts struct {
+	Failures     int
+	LastFailure  time.Time
+	BlockedUntil time.Time // До этого времени попытки отклоняются (экспоненциальная задержка)
+	LockedUntil  time.Time // Блокировка после MaxFailures неудач
+	Pending      int       // Начатые, но ещё не завершённые попытки
+	ReservedAt   time.Time // Время последней начатой попытки
+}
+
+// AttemptStore хранит счётчики попыток входа. Реализация в памяти подходит для
+// одного экземпляра сервера; для нескольких нужно общее хранилище (например,
+// Redis) с атомарным Update.
+type AttemptStore interface {
+	Get(key string) LoginAttempts
+	// Update атомарно изменяет запись по ключу и возвращает её новое значение
+	Update(key string, fn func(*LoginAttempts)) LoginAttempts
+	Delete(key string)
+}
+
+// MemoryAttemptStore — AttemptStore в памяти процесса. Записи без неудач и
+// попыток дольше ttl удаляются при очередном обращении.
+type MemoryAttemptStore struct {
+	mu      sync.Mutex
+	entries map[string]LoginAttempts
+	ttl     time.Duration
+	swept   time.Time
+}
+
+func NewMemoryAttemptStore(ttl time.Duration) *MemoryAttemptStore {
+	return &MemoryAttemptStore{entries: make(map[string]LoginAttempts), ttl: ttl}
+}
+
+func (s *MemoryAttemptStore) Get(key string) LoginAttempts {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[key]
+}
+
+func (s *MemoryAttemptStore) Update(key string, fn func(*LoginAttempts)) LoginAttempts {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.swept) > s.ttl {
+		for k, a := range s.entries {
+			if now.Sub(a.LastFailure) > s.ttl && now.Sub(a.ReservedAt) > s.ttl && now.After(a.LockedUntil) {
+				delete(s.entries, k)
+			}
+		}
+		s.swept = now
+	}
+
+	a := s.entries[key]
+	fn(&a)
+	s.entries[key] = a
+	return a
+}
+
+func (s *MemoryAttemptStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+// LoginLimiter защищает вход от перебора паролей. Неудачи считаются отдельно
+// по логину и по IP: после FreeAttempts каждая следующая неудача удваивает
+// задержку до следующей попытки (от BaseDelay до MaxDelay), а после
+// MaxFailures (IPMaxFailures для IP) вход блокируется на LockoutDuration.
+// Счётчик сбрасывается после ResetAfter без неудач.
+//
+// Попытка резервируется до проверки пароля, поэтому параллельные запросы не
+// обходят задержку: незавершённая попытка считается возможной неудачей.
+// Резерв, не завершённый за AttemptTimeout, перестаёт учитываться.
+type LoginLimiter struct {
+	Store           AttemptStore
+	FreeAttempts    int
+	MaxFailures     int
+	IPMaxFailures   int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutDuration time.Duration
+	ResetAfter      time.Duration
+	AttemptTimeout  time.Duration
+}
+
+func NewLoginLimiter(store AttemptStore) *LoginLimiter {
+	return &LoginLimiter{
+		Store:           store,
+		FreeAttempts:    3,
+		MaxFailures:     10,
+		IPMaxFailures:   50,
+		BaseDelay:       time.Second,
+		MaxDelay:        5 * time.Minute,
+		LockoutDuration: 15 * time.Minute,
+		ResetAfter:      time.Hour,
+		AttemptTimeout:  30 * time.Second,
+	}
+}
+
+// LoginDenial — причина отказа в попытке входа.
+type LoginDenial struct {
+	RetryAfter time.Duration
+	Locked     bool // Блокировка, а не задержка между попытками
+}
+
+func loginKey(login string) string {
+	return "login:" + strings.ToLower(strings.TrimSpace(login))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// LoginAttempt — зарезервированная попытка входа. Завершается вызовом Fail
+// или Succeed; Release снимает резерв, если попытка не завершилась ни тем, ни
+// другим (например, вход продолжается вторым шагом).
+type LoginAttempt struct {
+	limiter   *LoginLimiter
+	login, ip string
+	done      bool
+}
+
+// Begin атомарно проверяет, допускается ли попытка входа с логином login с
+// адреса ip, и резервирует её. При отказе возвращает его причину.
+func (l *LoginLimiter) Begin(login, ip string) (*LoginAttempt, *LoginDenial) {
+	if denial := l.reserve(loginKey(login), l.MaxFailures); denial != nil {
+		return nil, denial
+	}
+	if denial := l.reserve(ipKey(ip), l.IPMaxFailures); denial != nil {
+		l.release(loginKey(login))
+		return nil, denial
+	}
+	return &LoginAttempt{limiter: l, login: login, ip: ip}, nil
+}
+
+func (l *LoginLimiter) reserve(key string, maxFailures int) (denial *LoginDenial) {
+	now := time.Now()
+	l.Store.Update(key, func(a *LoginAttempts) {
+		if now.Sub(a.ReservedAt) > l.AttemptTimeout {
+			a.Pending = 0
+		}
+		if now.Before(a.LockedUntil) {
+			denial = &LoginDenial{RetryAfter: a.LockedUntil.Sub(now), Locked: true}
+			return
+		}
+		if now.Before(a.BlockedUntil) {
+			denial = &LoginDenial{RetryAfter: a.BlockedUntil.Sub(now)}
+			return
+		}
+
+		failures := a.Failures
+		if now.Sub(a.LastFailure) > l.ResetAfter {
+			failures = 0
+		}
+		// Сверх бесплатных попыток следующая допускается только после
+		// завершения предыдущей, иначе задержка и блокировка не сработают
+		if a.Pending > 0 && (failures+a.Pending >= l.FreeAttempts || failures+a.Pending >= maxFailures) {
+			denial = &LoginDenial{RetryAfter: l.BaseDelay}
+			return
+		}
+		a.Pending++
+		a.ReservedAt = now
+	})
+	return denial
+}
+
+func (l *LoginLimiter) release(key string) {
+	l.Store.Update(key, func(a *LoginAttempts) {
+		if a.Pending > 0 {
+			a.Pending--
+		}
+	})
+}
+
+// Fail учитывает неудачу попытки и возвращает true, если она привела к
+// блокировке логина или IP.
+func (a *LoginAttempt) Fail() (locked bool) {
+	if a.done {
+		return false
+	}
+	a.done = true
+	loginLocked := a.limiter.fail(loginKey(a.login), a.limiter.MaxFailures)
+	ipLocked := a.limiter.fail(ipKey(a.ip), a.limiter.IPMaxFailures)
+	return loginLocked || ipLocked
+}
+
+// Succeed завершает попытку успешным входом.
+func (a *LoginAttempt) Succeed() {
+	if a.done {
+		return
+	}
+	a.done = true
+	a.limiter.release(ipKey(a.ip))
+	a.limiter.Succeed(a.login)
+}
+
+// Release снимает резерв незавершённой попытки; после Fail или Succeed ничего
+// не делает.
+func (a *LoginAttempt) Release() {
+	if a.done {
+		return
+	}
+	a.done = true
+	a.limiter.release(loginKey(a.login))
+	a.limiter.release(ipKey(a.ip))
+}
+
+func (l *LoginLimiter) fail(key string, maxFailures int) (locked bool) {
+	now := time.Now()
+	l.Store.Update(key, func(a *LoginAttempts) {
+		if a.Pending > 0 {
+			a.Pending--
+		}
+		if now.Sub(a.LastFailure) > l.ResetAfter {
+			a.Failures = 0
+			a.BlockedUntil = time.Time{}
+			a.LockedUntil = time.Time{}
+		}
+		a.Failures++
+		a.LastFailure = now
+
+		if a.Failures >= maxFailures {
+			a.LockedUntil = now.Add(l.LockoutDuration)
+			a.Failures = 0
+			locked = true
+			return
+		}
+		if extra := a.Failures - l.FreeAttempts; extra > 0 {
+			delay := l.MaxDelay
+			if extra <= 20 {
+				if d := l.BaseDelay << (extra - 1); d < l.MaxDelay {
+					delay = d
+				}
+			}
+			a.BlockedUntil = now.Add(delay)
+		}
+	})
+	return locked
+}
+
+// Succeed сбрасывает счётчик логина после успешного входа. Счётчик IP не
+// сбрасывается, чтобы перебор разных логинов с одного адреса оставался виден.
+func (l *LoginLimiter) Succeed(login string) {
+	l.Store.Delete(loginKey(login))
+}
+
+// Unlock снимает блокировку и задержки логина и, если задан, IP-адреса.
+func (l *LoginLimiter) Unlock(login, ip string) {
+	if login != "" {
+		l.Store.Delete(loginKey(login))
+	}
+	if ip != "" {
+		l.Store.Delete(ipKey(ip))
+	}
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestLimiter() *LoginLimiter {
+	l := NewLoginLimiter(NewMemoryAttemptStore(time.Hour))
+	l.FreeAttempts = 3
+	l.MaxFailures = 10
+	l.IPMaxFailures = 50
+	l.BaseDelay = time.Second
+	l.MaxDelay = 4 * time.Second
+	return l
+}
+
+// failLogin проводит неудачную попытку входа, снимая оставшуюся задержку,
+// чтобы тесту не приходилось её ждать.
+func failLogin(t *testing.T, l *LoginLimiter, login, ip string) bool {
+	t.Helper()
+	for _, key := range []string{loginKey(login), ipKey(ip)} {
+		l.Store.Update(key, func(a *LoginAttempts) { a.BlockedUntil = time.Time{} })
+	}
+	attempt, denial := l.Begin(login, ip)
+	if denial != nil {
+		t.Fatalf("попытка отклонена: %+v", denial)
+	}
+	return attempt.Fail()
+}
+
+func TestLoginLimiterBackoff(t *testing.T) {
+	l := newTestLimiter()
+
+	// Задержка после каждой неудачи сверх бесплатных удваивается до MaxDelay
+	want := []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}
+	for i, delay := range want {
+		failLogin(t, l, "user", "10.0.0.1")
+		a := l.Store.Get(loginKey("user"))
+		got := time.Duration(0)
+		if !a.BlockedUntil.IsZero() {
+			got = a.BlockedUntil.Sub(a.LastFailure)
+		}
+		if got != delay {
+			t.Errorf("неудача %d: задержка %v, ожидалась %v", i+1, got, delay)
+		}
+	}
+
+	_, denial := l.Begin("user", "10.0.0.1")
+	if denial == nil || denial.Locked || denial.RetryAfter <= 0 || denial.RetryAfter > l.MaxDelay {
+		t.Errorf("во время задержки ожидался отказ без блокировки, получено %+v", denial)
+	}
+
+	// Задержка логина и адреса не мешает входу под другим логином с другого адреса
+	attempt, denial := l.Begin("other", "10.0.0.2")
+	if denial != nil {
+		t.Fatalf("другой логин отклонён: %+v", denial)
+	}
+	attempt.Release()
+}
+
+func TestLoginLimiterLockout(t *testing.T) {
+	l := newTestLimiter()
+	l.MaxFailures = 5
+
+	for i := 1; i <= l.MaxFailures; i++ {
+		if locked := failLogin(t, l, "user", "10.0.0.1"); locked != (i == l.MaxFailures) {
+			t.Fatalf("неудача %d: блокировка %v", i, locked)
+		}
+	}
+
+	_, denial := l.Begin("user", "10.0.0.2")
+	if denial == nil || !denial.Locked {
+		t.Fatalf("ожидалась блокировка логина, получено %+v", denial)
+	}
+	if denial.RetryAfter <= l.LockoutDuration-time.Minute || denial.RetryAfter > l.LockoutDuration {
+		t.Errorf("блокировка на %v, ожидалось около %v", denial.RetryAfter, l.LockoutDuration)
+	}
+
+	l.Unlock("user", "")
+	attempt, denial := l.Begin("user", "10.0.0.2")
+	if denial != nil {
+		t.Fatalf("после снятия блокировки попытка отклонена: %+v", denial)
+	}
+	attempt.Succeed()
+}
+
+func TestLoginLimiterIPLockout(t *testing.T) {
+	l := newTestLimiter()
+	l.IPMaxFailures = 4
+
+	// Перебор разных логинов с одного адреса блокирует адрес
+	for i, login := range []string{"a", "b", "c", "d"} {
+		if locked := failLogin(t, l, login, "10.0.0.1"); locked != (i == 3) {
+			t.Fatalf("неудача %d: блокировка %v", i+1, locked)
+		}
+	}
+
+	if _, denial := l.Begin("e", "10.0.0.1"); denial == nil || !denial.Locked {
+		t.Fatalf("ожидалась блокировка адреса, получено %+v", denial)
+	}
+	if a := l.Store.Get(loginKey("e")); a.Pending != 0 {
+		t.Errorf("резерв логина при отказе по адресу не снят: %d", a.Pending)
+	}
+
+	attempt, denial := l.Begin("e", "10.0.0.2")
+	if denial != nil {
+		t.Fatalf("вход с другого адреса отклонён: %+v", denial)
+	}
+	attempt.Release()
+}
+
+func TestLoginLimiterSucceedKeepsIPCounter(t *testing.T) {
+	l := newTestLimiter()
+	failLogin(t, l, "user", "10.0.0.1")
+	failLogin(t, l, "user", "10.0.0.1")
+
+	attempt, denial := l.Begin("user", "10.0.0.1")
+	if denial != nil {
+		t.Fatalf("попытка отклонена: %+v", denial)
+	}
+	attempt.Succeed()
+
+	if a := l.Store.Get(loginKey("user")); a.Failures != 0 || a.Pending != 0 {
+		t.Errorf("счётчик логина не сброшен: %+v", a)
+	}
+	if a := l.Store.Get(ipKey("10.0.0.1")); a.Failures != 2 || a.Pending != 0 {
+		t.Errorf("счётчик адреса: %+v, ожидалось 2 неудачи без резерва", a)
+	}
+}
+
+func TestLoginLimiterConcurrentReservation(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		granted  int
+	}{
+		{"без неудач допускаются только бесплатные попытки", 0, 3},
+		{"после неудач допускается одна попытка", 2, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLimiter()
+			for i := 0; i < tt.failures; i++ {
+				failLogin(t, l, "user", "10.0.0.1")
+			}
+
+			const n = 20
+			attempts := make([]*LoginAttempt, n)
+			start := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					<-start
+					attempts[i], _ = l.Begin("user", "10.0.0.1")
+				}(i)
+			}
+			close(start)
+			wg.Wait()
+
+			granted := 0
+			for _, attempt := range attempts {
+				if attempt != nil {
+					granted++
+				}
+			}
+			if granted != tt.granted {
+				t.Fatalf("допущено %d параллельных попыток, ожидалось %d", granted, tt.granted)
+			}
+
+			for _, attempt := range attempts {
+				if attempt != nil {
+					attempt.Release()
+				}
+			}
+			if a := l.Store.Get(loginKey("user")); a.Pending != 0 {
+				t.Errorf("после Release осталось %d резервов", a.Pending)
+			}
+		})
+	}
+}
+
+func TestLoginLimiterStaleReservation(t *testing.T) {
+	l := newTestLimiter()
+	l.FreeAttempts = 1
+
+	if _, denial := l.Begin("user", "10.0.0.1"); denial != nil {
+		t.Fatalf("первая попытка отклонена: %+v", denial)
+	}
+	if _, denial := l.Begin("user", "10.0.0.1"); denial == nil {
+		t.Fatal("вторая попытка допущена при незавершённой первой")
+	}
+
+	// Незавершённый за AttemptTimeout резерв перестаёт учитываться
+	for _, key := range []string{loginKey("user"), ipKey("10.0.0.1")} {
+		l.Store.Update(key, func(a *LoginAttempts) { a.ReservedAt = time.Now().Add(-2 * l.AttemptTimeout) })
+	}
+	attempt, denial := l.Begin("user", "10.0.0.1")
+	if denial != nil {
+		t.Fatalf("попытка после истечения резерва отклонена: %+v", denial)
+	}
+	attempt.Release()
+}
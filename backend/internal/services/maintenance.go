@@ -0,0 +1,121 @@
+package services
+
+import (
+	"time"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// MaintenanceWindow — тех. окно по умолчанию, если ни одно правило не подходит.
+const MaintenanceWindow = 20 * time.Minute
+
+// MaintenanceRules — правила тех. окон, загруженные для одной проверки, с
+// кэшем типов поездов и станций путей.
+type MaintenanceRules struct {
+	db            *gorm.DB
+	rules         []models.MaintenanceRule
+	trainTypes    map[uint]models.TrainType
+	trackStations map[uint]uint
+}
+
+func LoadMaintenanceRules() *MaintenanceRules {
+	return loadMaintenanceRules(database.DB)
+}
+
+func loadMaintenanceRules(db *gorm.DB) *MaintenanceRules {
+	r := &MaintenanceRules{
+		db:            db,
+		trainTypes:    make(map[uint]models.TrainType),
+		trackStations: make(map[uint]uint),
+	}
+	db.Find(&r.rules)
+	return r
+}
+
+// Window возвращает тех. окно между рейсом поезда типа prevType и следующим за
+// ним рейсом типа nextType на пути trackID станции stationID. Вес совпадения:
+// путь — 8, станция — 4, тип предыдущего поезда — 2, тип поезда — 1; при
+// равном весе действует больший интервал.
+func (r *MaintenanceRules) Window(trackID, stationID *uint, prevType, nextType models.TrainType) time.Duration {
+	if trackID != nil && stationID == nil {
+		stationID = r.trackStation(*trackID)
+	}
+
+	best, bestScore := MaintenanceWindow, -1
+	for _, rule := range r.rules {
+		score := 0
+		if rule.TrackID != nil {
+			if trackID == nil || *rule.TrackID != *trackID {
+				continue
+			}
+			score += 8
+		}
+		if rule.StationID != nil {
+			if stationID == nil || *rule.StationID != *stationID {
+				continue
+			}
+			score += 4
+		}
+		if rule.PrevTrainType != nil {
+			if *rule.PrevTrainType != prevType {
+				continue
+			}
+			score += 2
+		}
+		if rule.TrainType != nil {
+			if *rule.TrainType != nextType {
+				continue
+			}
+			score += 1
+		}
+
+		window := time.Duration(rule.Minutes) * time.Minute
+		if score > bestScore || (score == bestScore && window > best) {
+			best, bestScore = window, score
+		}
+	}
+	return best
+}
+
+// Between возвращает тех. окно между двумя рейсами на пути.
+func (r *MaintenanceRules) Between(trackID, stationID *uint, prev, next *models.Schedule) time.Duration {
+	return r.Window(trackID, stationID, r.TrainType(prev.TrainID), r.TrainType(next.TrainID))
+}
+
+// Max возвращает наибольшее тех. окно среди всех правил.
+func (r *MaintenanceRules) Max() time.Duration {
+	max := MaintenanceWindow
+	for _, rule := range r.rules {
+		if window := time.Duration(rule.Minutes) * time.Minute; window > max {
+			max = window
+		}
+	}
+	return max
+}
+
+func (r *MaintenanceRules) TrainType(trainID uint) models.TrainType {
+	if trainType, ok := r.trainTypes[trainID]; ok {
+		return trainType
+	}
+	var train models.Train
+	r.db.Select("id", "type").First(&train, trainID)
+	r.trainTypes[trainID] = train.Type
+	return train.Type
+}
+
+func (r *MaintenanceRules) trackStation(trackID uint) *uint {
+	stationID, ok := r.trackStations[trackID]
+	if !ok {
+		var track models.Track
+		r.db.Select("id", "station_id").First(&track, trackID)
+		stationID = track.StationID
+		r.trackStations[trackID] = stationID
+	}
+	if stationID == 0 {
+		return nil
+	}
+	return &stationID
+}
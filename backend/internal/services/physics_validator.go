@@ -2,14 +2,36 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"time"
 
 	"railway-dispatcher/internal/database"
 	"railway-dispatcher/internal/models"
+
+	"gorm.io/gorm"
 )
 
+const earthRadiusKm = 6371.0
+
 type PhysicsValidator struct{}
 
+type PhysicsError struct {
+	Message     string
+	DistanceKm  float64
+	SpeedKmh    float64
+	MinDuration time.Duration
+}
+
+func (e *PhysicsError) Error() string {
+	return e.Message
+}
+
+type Segment struct {
+	DistanceKm float64
+	MaxSpeed   float64 // Ограничение скорости на перегоне, 0 — без ограничения
+}
+
 func (v *PhysicsValidator) ValidateTravelPhysics(schedule *models.Schedule) error {
 	if schedule.FromStationID == nil || schedule.ToStationID == nil {
 		return nil
@@ -20,48 +42,127 @@ func (v *PhysicsValidator) ValidateTravelPhysics(schedule *models.Schedule) erro
 		return errors.New("поезд не найден")
 	}
 
-	travelTime := schedule.ArrivalTime.Sub(schedule.DepartureTime).Hours()
+	travelTime := schedule.ArrivalTime.Sub(schedule.DepartureTime)
 	if travelTime <= 0 {
 		return errors.New("время прибытия должно быть позже времени отправления")
 	}
 
+	if len(schedule.Stops) == 0 {
+		return checkLeg(*schedule.FromStationID, *schedule.ToStationID, &train, travelTime)
+	}
+
+	fromID, departure := *schedule.FromStationID, schedule.DepartureTime
+	for _, stop := range schedule.Stops {
+		if err := checkLeg(fromID, stop.StationID, &train, stop.ArrivalTime.Sub(departure)); err != nil {
+			return fmt.Errorf("перегон до остановки %d: %w", stop.Sequence, err)
+		}
+		fromID, departure = stop.StationID, stop.DepartureTime
+	}
+	if err := checkLeg(fromID, *schedule.ToStationID, &train, schedule.ArrivalTime.Sub(departure)); err != nil {
+		return fmt.Errorf("перегон до станции назначения: %w", err)
+	}
+
 	return nil
 }
 
-func GenerateRecurringSchedules(parent *models.Schedule, count int) ([]models.Schedule, error) {
-	if parent.Recurrence == models.RecurrenceNone {
-		return nil, nil
+func checkLeg(fromID, toID uint, train *models.Train, travelTime time.Duration) error {
+	if travelTime <= 0 {
+		return errors.New("время прибытия должно быть позже времени отправления")
+	}
+
+	segment, err := FindSegment(fromID, toID)
+	if err != nil {
+		return err
+	}
+	if segment == nil {
+		return nil
 	}
 
-	var schedules []models.Schedule
-	var interval time.Duration
+	return checkSegment(segment, train, travelTime)
+}
 
-	switch parent.Recurrence {
-	case models.RecurrenceDaily:
-		interval = 24 * time.Hour
-	case models.RecurrenceWeekly:
-		interval = 7 * 24 * time.Hour
-	case models.RecurrenceMonthly:
-		interval = 30 * 24 * time.Hour
-	default:
-		return nil, nil
+// segmentSpeed возвращает допустимую скорость поезда на перегоне.
+func segmentSpeed(segment *Segment, train *models.Train) float64 {
+	speed := train.MaxSpeed
+	if segment.MaxSpeed > 0 && segment.MaxSpeed < speed {
+		speed = segment.MaxSpeed
+	}
+	return speed
+}
+
+// MinTravelTime возвращает минимальное время хода поезда по перегону.
+func MinTravelTime(segment *Segment, train *models.Train) (time.Duration, error) {
+	speed := segmentSpeed(segment, train)
+	if speed <= 0 {
+		return 0, errors.New("у поезда не задана максимальная скорость")
+	}
+	return time.Duration(segment.DistanceKm / speed * float64(time.Hour)), nil
+}
+
+func checkSegment(segment *Segment, train *models.Train, travelTime time.Duration) error {
+	minDuration, err := MinTravelTime(segment, train)
+	if err != nil {
+		return err
 	}
+	speed := segmentSpeed(segment, train)
 
-	for i := 1; i <= count; i++ {
-		schedule := models.Schedule{
-			TrainID:       parent.TrainID,
-			TrackNumber:   parent.TrackNumber,
-			DepartureTime: parent.DepartureTime.Add(interval * time.Duration(i)),
-			ArrivalTime:   parent.ArrivalTime.Add(interval * time.Duration(i)),
-			Status:        models.StatusScheduled,
-			Recurrence:    models.RecurrenceNone,
-			FromStationID: parent.FromStationID,
-			ToStationID:   parent.ToStationID,
-			ParentID:      &parent.ID,
-			CreatedByID:   parent.CreatedByID,
+	if travelTime < minDuration {
+		return &PhysicsError{
+			Message: fmt.Sprintf("нарушение физики: %.1f км при скорости %.0f км/ч требуют минимум %d мин в пути",
+				segment.DistanceKm, speed, int(math.Ceil(minDuration.Minutes()))),
+			DistanceKm:  segment.DistanceKm,
+			SpeedKmh:    speed,
+			MinDuration: minDuration,
 		}
-		schedules = append(schedules, schedule)
 	}
 
-	return schedules, nil
+	return nil
+}
+
+// FindSegment возвращает перегон между станциями: явно заданный в StationDistance,
+// либо расстояние по дуге большого круга, если у обеих станций есть координаты.
+// nil без ошибки означает, что расстояние определить нельзя.
+func FindSegment(fromID, toID uint) (*Segment, error) {
+	if fromID == toID {
+		return nil, nil
+	}
+
+	var distance models.StationDistance
+	err := database.DB.Where(
+		"(from_station_id = ? AND to_station_id = ?) OR (from_station_id = ? AND to_station_id = ? AND bidirectional = ?)",
+		fromID, toID, toID, fromID, true,
+	).Order("distance_km ASC").First(&distance).Error
+	if err == nil {
+		return &Segment{DistanceKm: distance.DistanceKm, MaxSpeed: distance.MaxSpeed}, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var from, to models.Station
+	if err := database.DB.First(&from, fromID).Error; err != nil {
+		return nil, errors.New("станция отправления не найдена")
+	}
+	if err := database.DB.First(&to, toID).Error; err != nil {
+		return nil, errors.New("станция назначения не найдена")
+	}
+	if !hasCoordinates(&from) || !hasCoordinates(&to) {
+		return nil, nil
+	}
+
+	return &Segment{DistanceKm: GreatCircleKm(from.Latitude, from.Longitude, to.Latitude, to.Longitude)}, nil
+}
+
+func hasCoordinates(s *models.Station) bool {
+	return s.Latitude != 0 || s.Longitude != 0
+}
+
+func GreatCircleKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
 }
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/models"
+)
+
+// LegacyRRule переводит прежние значения Recurrence (daily, weekly, monthly,
+// custom:<дни месяца>) с количеством повторений в правило RRULE.
+// Исходный рейс входит в COUNT, поэтому повторений на одно больше.
+func LegacyRRule(recurrence models.Recurrence, count int) (string, error) {
+	if count <= 0 {
+		return "", nil
+	}
+	if count >= MaxOccurrences {
+		count = MaxOccurrences - 1
+	}
+	total := count + 1
+
+	switch {
+	case recurrence == models.RecurrenceDaily:
+		return fmt.Sprintf("FREQ=DAILY;COUNT=%d", total), nil
+	case recurrence == models.RecurrenceWeekly:
+		return fmt.Sprintf("FREQ=WEEKLY;COUNT=%d", total), nil
+	case recurrence == models.RecurrenceMonthly:
+		return fmt.Sprintf("FREQ=MONTHLY;COUNT=%d", total), nil
+	case strings.HasPrefix(string(recurrence), "custom:"):
+		days := strings.TrimPrefix(string(recurrence), "custom:")
+		return fmt.Sprintf("FREQ=MONTHLY;BYMONTHDAY=%s;COUNT=%d", days, total), nil
+	default:
+		return "", nil
+	}
+}
+
+// StationLocation возвращает часовой пояс станции отправления рейса.
+func StationLocation(stationID *uint) *time.Location {
+	if stationID == nil {
+		return time.Local
+	}
+	var station models.Station
+	if err := database.DB.Select("time_zone").First(&station, *stationID).Error; err != nil || station.TimeZone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(station.TimeZone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// ParseExDates разбирает даты-исключения (праздники) в формате ГГГГ-ММ-ДД.
+func ParseExDates(values []string) (map[string]bool, error) {
+	exdates := make(map[string]bool)
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		day, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, fmt.Errorf("неверная дата исключения: %s", value)
+		}
+		exdates[day.Format("2006-01-02")] = true
+	}
+	return exdates, nil
+}
+
+// ExpandRecurrence порождает повторения рейса по правилу parent.RRule в часовом
+// поясе станции отправления. Сам родительский рейс в результат не входит.
+func ExpandRecurrence(parent *models.Schedule) ([]models.Schedule, error) {
+	if parent.RRule == "" {
+		return nil, nil
+	}
+
+	loc := StationLocation(parent.FromStationID)
+	rule, err := ParseRRule(parent.RRule, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	var exValues []string
+	if parent.ExDates != "" {
+		exValues = strings.Split(parent.ExDates, ",")
+	}
+	exdates, err := ParseExDates(exValues)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := parent.ArrivalTime.Sub(parent.DepartureTime)
+	if duration <= 0 {
+		return nil, errors.New("время прибытия должно быть позже времени отправления")
+	}
+
+	var schedules []models.Schedule
+	for _, departure := range rule.Expand(parent.DepartureTime, loc, exdates) {
+		if departure.Equal(parent.DepartureTime) {
+			continue
+		}
+		shift := departure.Sub(parent.DepartureTime)
+
+		schedule := models.Schedule{
+			TrainID:          parent.TrainID,
+			TrackNumber:      parent.TrackNumber,
+			DepartureTrackID: parent.DepartureTrackID,
+			ArrivalTrackID:   parent.ArrivalTrackID,
+			DepartureTime:    departure,
+			ArrivalTime:      departure.Add(duration),
+			Status:           models.StatusScheduled,
+			Recurrence:       models.RecurrenceNone,
+			FromStationID:    parent.FromStationID,
+			ToStationID:      parent.ToStationID,
+			ParentID:         &parent.ID,
+			CreatedByID:      parent.CreatedByID,
+		}
+		for _, stop := range parent.Stops {
+			schedule.Stops = append(schedule.Stops, models.RouteStop{
+				Sequence:      stop.Sequence,
+				StationID:     stop.StationID,
+				TrackID:       stop.TrackID,
+				ArrivalTime:   stop.ArrivalTime.Add(shift),
+				DepartureTime: stop.DepartureTime.Add(shift),
+				DwellMinutes:  stop.DwellMinutes,
+			})
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, nil
+}
+
+type OccurrenceReport struct {
+	Index         int       `json:"index"`
+	DepartureTime time.Time `json:"departure_time"`
+	ArrivalTime   time.Time `json:"arrival_time"`
+	ScheduleID    uint      `json:"schedule_id,omitempty"`
+	Created       bool      `json:"created"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// ValidateOccurrences проверяет каждое повторение на коллизии путей и занятость
+// поезда среди уже существующих рейсов, а также на пересечение с предыдущими
+// повторениями той же серии.
+func (v *ScheduleValidator) ValidateOccurrences(parent *models.Schedule, occurrences []models.Schedule) ([]OccurrenceReport, bool) {
+	reports := make([]OccurrenceReport, len(occurrences))
+	hasConflicts := false
+	prevArrival := parent.ArrivalTime
+	trains := &TrainAvailabilityValidator{}
+
+	rules := LoadMaintenanceRules()
+	trainType := rules.TrainType(parent.TrainID)
+	trackID := parent.DepartureTrackID
+	if trackID == nil {
+		trackID = parent.ArrivalTrackID
+	}
+	gap := rules.Window(trackID, parent.FromStationID, trainType, trainType)
+	if TrainTurnaround > gap {
+		gap = TrainTurnaround
+	}
+
+	for i := range occurrences {
+		occ := &occurrences[i]
+		reports[i] = OccurrenceReport{Index: i + 1, DepartureTime: occ.DepartureTime, ArrivalTime: occ.ArrivalTime}
+
+		var err error
+		if occ.DepartureTime.Before(prevArrival.Add(gap)) {
+			err = errors.New("повторение пересекается с предыдущим рейсом серии")
+		} else if err = v.ValidateSchedule(occ); err == nil {
+			err = trains.ValidateTrainOverlap(occ)
+		}
+
+		if err != nil {
+			reports[i].Error = err.Error()
+			hasConflicts = true
+			continue
+		}
+		prevArrival = occ.ArrivalTime
+	}
+
+	return reports, hasConflicts
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/models"
+)
+
+// ValidateStops проверяет порядок и времена промежуточных остановок маршрута.
+// Время стоянки вычисляется из прибытия и отправления, либо отправление — из стоянки.
+func (v *ScheduleValidator) ValidateStops(schedule *models.Schedule) error {
+	if len(schedule.Stops) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(schedule.Stops, func(i, j int) bool {
+		return schedule.Stops[i].Sequence < schedule.Stops[j].Sequence
+	})
+
+	prevTime := schedule.DepartureTime
+	prevStationID := schedule.FromStationID
+	for i := range schedule.Stops {
+		stop := &schedule.Stops[i]
+		stop.Sequence = i + 1
+
+		if stop.ArrivalTime.IsZero() {
+			return fmt.Errorf("остановка %d: не указано время прибытия", stop.Sequence)
+		}
+		if stop.DepartureTime.IsZero() {
+			stop.DepartureTime = stop.ArrivalTime.Add(time.Duration(stop.DwellMinutes) * time.Minute)
+		}
+		if stop.DepartureTime.Before(stop.ArrivalTime) {
+			return fmt.Errorf("остановка %d: отправление должно быть не раньше прибытия", stop.Sequence)
+		}
+		stop.DwellMinutes = int(stop.DepartureTime.Sub(stop.ArrivalTime).Minutes())
+
+		if !stop.ArrivalTime.After(prevTime) {
+			return fmt.Errorf("остановка %d: прибытие должно быть позже отправления с предыдущей станции", stop.Sequence)
+		}
+		if prevStationID != nil && *prevStationID == stop.StationID {
+			return fmt.Errorf("остановка %d: станция совпадает с предыдущей", stop.Sequence)
+		}
+
+		var station models.Station
+		if err := database.DB.First(&station, stop.StationID).Error; err != nil {
+			return fmt.Errorf("остановка %d: станция не найдена", stop.Sequence)
+		}
+		if stop.TrackID != nil {
+			if _, err := loadStationTrack(*stop.TrackID, &stop.StationID); err != nil {
+				return fmt.Errorf("остановка %d: %s", stop.Sequence, err.Error())
+			}
+		}
+
+		prevTime = stop.DepartureTime
+		stationID := stop.StationID
+		prevStationID = &stationID
+	}
+
+	if !schedule.ArrivalTime.After(prevTime) {
+		return errors.New("прибытие на конечную станцию должно быть позже отправления с последней остановки")
+	}
+	if prevStationID != nil && schedule.ToStationID != nil && *prevStationID == *schedule.ToStationID {
+		return errors.New("последняя остановка совпадает со станцией назначения")
+	}
+
+	return nil
+}
+
+// validateStopTracks проверяет занятость путей на промежуточных остановках
+// и остановки других рейсов на путях самого рейса в моменты отправления и
+// прибытия.
+func (v *ScheduleValidator) validateStopTracks(schedule *models.Schedule, rules *MaintenanceRules) error {
+	trainType := rules.TrainType(schedule.TrainID)
+
+	for _, stop := range schedule.Stops {
+		if stop.TrackID == nil {
+			continue
+		}
+
+		scope := stationTrackScope(*stop.TrackID, stop.ArrivalTime, stop.DepartureTime)
+		if err := v.validateTrackScope(scope, schedule, rules); err != nil {
+			return fmt.Errorf("остановка %d: %s", stop.Sequence, err.Error())
+		}
+		window := rules.Window(stop.TrackID, &stop.StationID, "", trainType)
+		if err := v.stopConflict(*stop.TrackID, stop.ArrivalTime, stop.DepartureTime, schedule.ID, window); err != nil {
+			return fmt.Errorf("остановка %d: %s", stop.Sequence, err.Error())
+		}
+	}
+
+	for _, scope := range scheduleTrackScopes(schedule) {
+		if scope.trackID == nil {
+			continue
+		}
+		window := rules.Window(scope.trackID, nil, "", trainType)
+		if err := v.stopConflict(*scope.trackID, scope.from, scope.to, schedule.ID, window); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *ScheduleValidator) stopConflict(trackID uint, from, to time.Time, excludeScheduleID uint, window time.Duration) error {
+	var count int64
+	v.conn().Model(&models.RouteStop{}).
+		Joins("JOIN schedules ON schedules.id = route_stops.schedule_id AND schedules.deleted_at IS NULL AND schedules.status != ?", models.StatusCancelled).
+		Where("route_stops.track_id = ? AND route_stops.schedule_id NOT IN ? AND route_stops.arrival_time < ? AND route_stops.departure_time > ?",
+			trackID, excludedIDs(v.exclude, excludeScheduleID), to.Add(window), from.Add(-window)).
+		Count(&count)
+	if count > 0 {
+		return errors.New("коллизия: путь занят стоянкой другого рейса")
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxOccurrences ограничивает число рейсов, порождаемых одним правилом повторения.
+const MaxOccurrences = 1000
+
+// maxPeriods ограничивает перебор периодов для правил, которые редко срабатывают.
+const maxPeriods = 50000
+
+type RRuleFreq string
+
+const (
+	FreqDaily   RRuleFreq = "DAILY"
+	FreqWeekly  RRuleFreq = "WEEKLY"
+	FreqMonthly RRuleFreq = "MONTHLY"
+	FreqYearly  RRuleFreq = "YEARLY"
+)
+
+type ByDay struct {
+	N       int // Порядковый номер в месяце (1MO, -1FR), 0 — каждый
+	Weekday time.Weekday
+}
+
+// RRule — подмножество правила повторения iCalendar (RFC 5545):
+// FREQ, INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY.
+type RRule struct {
+	Freq       RRuleFreq
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []ByDay
+	ByMonthDay []int
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+func ParseRRule(value string, loc *time.Location) (*RRule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		return nil, errors.New("пустое правило повторения")
+	}
+
+	rule := &RRule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("неверная часть правила: %s", part)
+		}
+		key = strings.ToUpper(strings.TrimSpace(key))
+		val = strings.ToUpper(strings.TrimSpace(val))
+
+		switch key {
+		case "FREQ":
+			switch RRuleFreq(val) {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				rule.Freq = RRuleFreq(val)
+			default:
+				return nil, fmt.Errorf("неподдерживаемая частота: %s", val)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, errors.New("INTERVAL должен быть положительным числом")
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, errors.New("COUNT должен быть положительным числом")
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseICalTime(val, loc)
+			if err != nil {
+				return nil, errors.New("неверный формат UNTIL")
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, item := range strings.Split(val, ",") {
+				day, err := parseByDay(item)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, item := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(item)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("неверный день месяца: %s", item)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "WKST":
+			if val != "MO" {
+				return nil, errors.New("поддерживается только WKST=MO")
+			}
+		default:
+			return nil, fmt.Errorf("неподдерживаемый параметр правила: %s", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, errors.New("в правиле не указана частота FREQ")
+	}
+	if rule.Count == 0 && rule.Until == nil {
+		return nil, errors.New("правило должно содержать COUNT или UNTIL")
+	}
+	if rule.Count > MaxOccurrences {
+		return nil, fmt.Errorf("COUNT не может превышать %d", MaxOccurrences)
+	}
+
+	return rule, nil
+}
+
+func parseByDay(item string) (ByDay, error) {
+	item = strings.TrimSpace(item)
+	if len(item) < 2 {
+		return ByDay{}, fmt.Errorf("неверный день недели: %s", item)
+	}
+	weekday, ok := weekdayCodes[item[len(item)-2:]]
+	if !ok {
+		return ByDay{}, fmt.Errorf("неверный день недели: %s", item)
+	}
+	day := ByDay{Weekday: weekday}
+	if prefix := item[:len(item)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return ByDay{}, fmt.Errorf("неверный день недели: %s", item)
+		}
+		day.N = n
+	}
+	return day, nil
+}
+
+func parseICalTime(value string, loc *time.Location) (time.Time, error) {
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse("20060102T150405Z", value)
+	}
+	if t, err := time.ParseInLocation("20060102T150405", value, loc); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("20060102", value, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// Дата без времени включает весь день
+	return t.AddDate(0, 0, 1).Add(-time.Second), nil
+}
+
+// Expand возвращает даты повторений начиная с start (включительно) в часовом
+// поясе loc: время суток берётся из start по местным часам, поэтому переход на
+// летнее время и разная длина месяцев не сдвигают расписание. Даты из exdates
+// пропускаются, но учитываются в COUNT, как того требует RFC 5545.
+func (r *RRule) Expand(start time.Time, loc *time.Location, exdates map[string]bool) []time.Time {
+	local := start.In(loc)
+	hour, minute, second := local.Clock()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hour, minute, second, 0, loc)
+	}
+
+	var result []time.Time
+	generated := 0
+
+	for period := 0; period < maxPeriods; period++ {
+		candidates := r.periodDates(local, period, at)
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+
+		for _, t := range candidates {
+			if t.Before(local) {
+				continue
+			}
+			if r.Until != nil && t.After(*r.Until) {
+				return result
+			}
+			generated++
+			if !exdates[t.Format("2006-01-02")] {
+				result = append(result, t)
+			}
+			if (r.Count > 0 && generated >= r.Count) || len(result) >= MaxOccurrences {
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
+func (r *RRule) periodDates(start time.Time, period int, at func(int, time.Month, int) time.Time) []time.Time {
+	var dates []time.Time
+	step := period * r.Interval
+
+	switch r.Freq {
+	case FreqDaily:
+		day := at(start.Year(), start.Month(), start.Day()+step)
+		if r.matchesWeekday(day.Weekday()) && r.matchesMonthDay(day) {
+			dates = append(dates, day)
+		}
+
+	case FreqWeekly:
+		offset := (int(start.Weekday()) + 6) % 7 // дней от понедельника
+		monday := at(start.Year(), start.Month(), start.Day()-offset+7*step)
+		if len(r.ByDay) == 0 {
+			return []time.Time{at(monday.Year(), monday.Month(), monday.Day()+offset)}
+		}
+		for _, d := range r.ByDay {
+			shift := (int(d.Weekday) + 6) % 7
+			dates = append(dates, at(monday.Year(), monday.Month(), monday.Day()+shift))
+		}
+
+	case FreqMonthly:
+		first := at(start.Year(), start.Month()+time.Month(step), 1)
+		dates = r.monthDates(first, start.Day(), at)
+
+	case FreqYearly:
+		first := at(start.Year()+step, start.Month(), 1)
+		dates = r.monthDates(first, start.Day(), at)
+	}
+
+	return dates
+}
+
+func (r *RRule) monthDates(first time.Time, defaultDay int, at func(int, time.Month, int) time.Time) []time.Time {
+	year, month := first.Year(), first.Month()
+	daysInMonth := at(year, month+1, 0).Day()
+
+	var dates []time.Time
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, d := range r.ByMonthDay {
+			if d < 0 {
+				d = daysInMonth + d + 1
+			}
+			if d >= 1 && d <= daysInMonth {
+				day := at(year, month, d)
+				if r.matchesWeekday(day.Weekday()) {
+					dates = append(dates, day)
+				}
+			}
+		}
+	case len(r.ByDay) > 0:
+		for _, d := range r.ByDay {
+			var matching []int
+			for day := 1; day <= daysInMonth; day++ {
+				if at(year, month, day).Weekday() == d.Weekday {
+					matching = append(matching, day)
+				}
+			}
+			switch {
+			case d.N == 0:
+				for _, day := range matching {
+					dates = append(dates, at(year, month, day))
+				}
+			case d.N > 0 && d.N <= len(matching):
+				dates = append(dates, at(year, month, matching[d.N-1]))
+			case d.N < 0 && -d.N <= len(matching):
+				dates = append(dates, at(year, month, matching[len(matching)+d.N]))
+			}
+		}
+	default:
+		// Месяцы без нужного числа пропускаются (например, 31-е)
+		if defaultDay <= daysInMonth {
+			dates = append(dates, at(year, month, defaultDay))
+		}
+	}
+
+	return dates
+}
+
+func (r *RRule) matchesWeekday(weekday time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, d := range r.ByDay {
+		if d.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) matchesMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	daysInMonth := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	for _, d := range r.ByMonthDay {
+		if d == t.Day() || (d < 0 && daysInMonth+d+1 == t.Day()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func moscow(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skipf("нет данных часового пояса: %v", err)
+	}
+	return loc
+}
+
+func TestExpand(t *testing.T) {
+	loc := moscow(t)
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 10, 0, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name    string
+		rule    string
+		start   time.Time
+		exdates []string
+		want    []time.Time
+	}{
+		{
+			name:  "второй вторник месяца",
+			rule:  "FREQ=MONTHLY;BYDAY=2TU;COUNT=3",
+			start: at(2024, 1, 1),
+			want:  []time.Time{at(2024, 1, 9), at(2024, 2, 13), at(2024, 3, 12)},
+		},
+		{
+			name:  "последняя пятница месяца",
+			rule:  "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			start: at(2024, 1, 1),
+			want:  []time.Time{at(2024, 1, 26), at(2024, 2, 23), at(2024, 3, 29)},
+		},
+		{
+			name:  "последний день месяца",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=4",
+			start: at(2024, 1, 1),
+			want:  []time.Time{at(2024, 1, 31), at(2024, 2, 29), at(2024, 3, 31), at(2024, 4, 30)},
+		},
+		{
+			name:  "месяцы без 31-го пропускаются",
+			rule:  "FREQ=MONTHLY;COUNT=4",
+			start: at(2024, 1, 31),
+			want:  []time.Time{at(2024, 1, 31), at(2024, 3, 31), at(2024, 5, 31), at(2024, 7, 31)},
+		},
+		{
+			name:  "UNTIL без времени включает весь день",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,WE;UNTIL=20240110",
+			start: at(2024, 1, 1),
+			want:  []time.Time{at(2024, 1, 1), at(2024, 1, 3), at(2024, 1, 8), at(2024, 1, 10)},
+		},
+		{
+			name:    "исключённые даты учитываются в COUNT",
+			rule:    "FREQ=DAILY;COUNT=5",
+			start:   at(2024, 1, 1),
+			exdates: []string{"2024-01-02", "2024-01-04"},
+			want:    []time.Time{at(2024, 1, 1), at(2024, 1, 3), at(2024, 1, 5)},
+		},
+		{
+			// 28.03.2010 Москва перешла на летнее время: местное время
+			// отправления сохраняется, смещение от UTC меняется с +3 на +4
+			name:  "переход на летнее время",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: at(2010, 3, 27),
+			want:  []time.Time{at(2010, 3, 27), at(2010, 3, 28), at(2010, 3, 29)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRRule(tt.rule, loc)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q): %v", tt.rule, err)
+			}
+			exdates := make(map[string]bool)
+			for _, d := range tt.exdates {
+				exdates[d] = true
+			}
+
+			got := rule.Expand(tt.start, loc, exdates)
+			if len(got) != len(tt.want) {
+				t.Fatalf("получено %d дат %v, ожидалось %d %v", len(got), got, len(tt.want), tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("дата %d: %v, ожидалось %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExpandKeepsLocalClockAcrossDST(t *testing.T) {
+	loc := moscow(t)
+	rule, err := ParseRRule("FREQ=DAILY;COUNT=2", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := rule.Expand(time.Date(2010, 3, 27, 10, 0, 0, 0, loc), loc, nil)
+	if len(got) != 2 {
+		t.Fatalf("получено %d дат, ожидалось 2", len(got))
+	}
+	if gap := got[1].Sub(got[0]); gap != 23*time.Hour {
+		t.Errorf("между рейсами %v, ожидалось 23h0m0s", gap)
+	}
+}
+
+func TestParseRRuleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+	}{
+		{"пустое правило", ""},
+		{"без частоты", "COUNT=3"},
+		{"без COUNT и UNTIL", "FREQ=DAILY"},
+		{"неподдерживаемая частота", "FREQ=HOURLY;COUNT=3"},
+		{"номер дня недели вне диапазона", "FREQ=MONTHLY;BYDAY=6MO;COUNT=3"},
+		{"нулевой день месяца", "FREQ=MONTHLY;BYMONTHDAY=0;COUNT=3"},
+		{"COUNT больше предела", "FREQ=DAILY;COUNT=1001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseRRule(tt.rule, time.UTC); err == nil {
+				t.Errorf("ParseRRule(%q) без ошибки", tt.rule)
+			}
+		})
+	}
+}
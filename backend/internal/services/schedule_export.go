@@ -0,0 +1,309 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"railway-dispatcher/internal/models"
+)
+
+// gtfsDefaultTimeZone — часовой пояс перевозчика в GTFS, если у станций он не задан.
+const gtfsDefaultTimeZone = "Europe/Moscow"
+
+// Экспорт ожидает рейсы с загруженными Train, FromStation, ToStation,
+// ArrivalTrack и Stops.Station.
+
+func stationCode(station *models.Station) string {
+	if station == nil {
+		return ""
+	}
+	return station.Code
+}
+
+func stationName(station *models.Station) string {
+	if station == nil {
+		return ""
+	}
+	return station.Name
+}
+
+func stationTimeZone(station *models.Station) *time.Location {
+	if station == nil || station.TimeZone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(station.TimeZone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// WriteScheduleCSV выгружает рейсы в CSV с теми же колонками, что принимает
+// ParseScheduleCSV, и дополнительными id и status. Время записывается в
+// часовом поясе станции.
+func WriteScheduleCSV(w io.Writer, schedules []models.Schedule) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{
+		"id", "train_number", "from_station", "to_station", "departure_time", "arrival_time",
+		"track_number", "arrival_track", "status",
+	})
+	for _, s := range schedules {
+		arrivalTrack := ""
+		if s.ArrivalTrack != nil {
+			arrivalTrack = strconv.Itoa(s.ArrivalTrack.Number)
+		}
+		writer.Write([]string{
+			strconv.FormatUint(uint64(s.ID), 10),
+			s.Train.Number,
+			stationCode(s.FromStation),
+			stationCode(s.ToStation),
+			s.DepartureTime.In(stationTimeZone(s.FromStation)).Format(time.RFC3339),
+			s.ArrivalTime.In(stationTimeZone(s.ToStation)).Format(time.RFC3339),
+			strconv.Itoa(s.TrackNumber),
+			arrivalTrack,
+			string(s.Status),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// icsEscape экранирует текст значения iCalendar (RFC 5545, 3.3.11).
+func icsEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(value)
+}
+
+// icsLine записывает строку iCalendar, перенося её после 75 байт.
+func icsLine(b *strings.Builder, line string) {
+	for len(line) > 75 {
+		cut := 75
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+// WriteScheduleICS выгружает рейсы в календарь iCalendar: каждый рейс —
+// событие от отправления до прибытия. Отменённые рейсы помечаются CANCELLED,
+// чтобы подписанные календари убрали их.
+func WriteScheduleICS(w io.Writer, schedules []models.Schedule, name string) error {
+	const layout = "20060102T150405Z"
+	now := time.Now().UTC().Format(layout)
+
+	var b strings.Builder
+	icsLine(&b, "BEGIN:VCALENDAR")
+	icsLine(&b, "VERSION:2.0")
+	icsLine(&b, "PRODID:-//railway-dispatcher//schedules//RU")
+	icsLine(&b, "CALSCALE:GREGORIAN")
+	icsLine(&b, "METHOD:PUBLISH")
+	if name != "" {
+		icsLine(&b, "X-WR-CALNAME:"+icsEscape(name))
+	}
+
+	for _, s := range schedules {
+		summary := "Поезд " + s.Train.Number
+		if s.FromStation != nil && s.ToStation != nil {
+			summary += ": " + s.FromStation.Name + " → " + s.ToStation.Name
+		}
+		description := fmt.Sprintf("Путь %d", s.TrackNumber)
+		if s.DelayMinutes > 0 {
+			description += fmt.Sprintf("\nОпоздание %d мин", s.DelayMinutes)
+		}
+		for _, stop := range s.Stops {
+			if stop.Station != nil {
+				description += fmt.Sprintf("\n%s %s", stop.ArrivalTime.In(stationTimeZone(stop.Station)).Format("15:04"), stop.Station.Name)
+			}
+		}
+
+		icsLine(&b, "BEGIN:VEVENT")
+		icsLine(&b, fmt.Sprintf("UID:schedule-%d@railway-dispatcher", s.ID))
+		icsLine(&b, "DTSTAMP:"+now)
+		icsLine(&b, "DTSTART:"+s.DepartureTime.UTC().Format(layout))
+		icsLine(&b, "DTEND:"+s.ArrivalTime.UTC().Format(layout))
+		icsLine(&b, "LAST-MODIFIED:"+s.UpdatedAt.UTC().Format(layout))
+		icsLine(&b, fmt.Sprintf("SEQUENCE:%d", s.Version))
+		icsLine(&b, "SUMMARY:"+icsEscape(summary))
+		if s.FromStation != nil {
+			icsLine(&b, "LOCATION:"+icsEscape(s.FromStation.Name))
+		}
+		icsLine(&b, "DESCRIPTION:"+icsEscape(description))
+		if s.Status == models.StatusCancelled {
+			icsLine(&b, "STATUS:CANCELLED")
+		} else {
+			icsLine(&b, "STATUS:CONFIRMED")
+		}
+		icsLine(&b, "END:VEVENT")
+	}
+	icsLine(&b, "END:VCALENDAR")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// gtfsClock форматирует время от начала служебных суток как ЧЧ:ММ:СС; рейсы
+// после полуночи получают часы больше 24, как требует GTFS.
+func gtfsClock(t, day time.Time) string {
+	d := t.Sub(day)
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+func writeGTFSFile(archive *zip.Writer, name string, header []string, rows [][]string) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(f)
+	writer.Write(header)
+	writer.WriteAll(rows)
+	return writer.Error()
+}
+
+// gtfsStop добавляет в stops остановку станции на пути track и возвращает её
+// stop_id. Каждый путь выгружается отдельной остановкой с platform_code и кодом
+// станции в stop_code, по которым импорт восстанавливает станцию и путь.
+// Станция без кода выгружается под своим идентификатором.
+func gtfsStop(stops map[string][]string, st *models.Station, track int) string {
+	code := st.Code
+	if code == "" {
+		code = strconv.FormatUint(uint64(st.ID), 10)
+	}
+	stopID, platform := code, ""
+	if track > 0 {
+		platform = strconv.Itoa(track)
+		stopID = code + ":" + platform
+	}
+	if _, ok := stops[stopID]; !ok {
+		stops[stopID] = []string{
+			stopID, code, st.Name,
+			strconv.FormatFloat(st.Latitude, 'f', 6, 64), strconv.FormatFloat(st.Longitude, 'f', 6, 64),
+			st.TimeZone, platform,
+		}
+	}
+	return stopID
+}
+
+// WriteScheduleGTFS выгружает рейсы в архив GTFS, который принимает ParseGTFS:
+// поезд становится маршрутом, каждый рейс — поездкой на свою дату в
+// calendar_dates.txt. Отменённые рейсы не выгружаются.
+func WriteScheduleGTFS(w io.Writer, schedules []models.Schedule) error {
+	tzName := gtfsDefaultTimeZone
+	for _, s := range schedules {
+		if s.FromStation != nil && s.FromStation.TimeZone != "" {
+			tzName = s.FromStation.TimeZone
+			break
+		}
+	}
+	loc, err := time.LoadLocation(tzName)
+	if err != nil {
+		return err
+	}
+
+	stops := make(map[string][]string)
+	routes := make(map[uint]*models.Train)
+	services := make(map[string]bool)
+	var trips, stopTimes [][]string
+
+	for i := range schedules {
+		s := &schedules[i]
+		if s.Status == models.StatusCancelled || s.FromStation == nil || s.ToStation == nil {
+			continue
+		}
+
+		departure := s.DepartureTime.In(loc)
+		day := time.Date(departure.Year(), departure.Month(), departure.Day(), 0, 0, 0, 0, loc)
+		serviceID := day.Format("20060102")
+		tripID := strconv.FormatUint(uint64(s.ID), 10)
+
+		services[serviceID] = true
+		routes[s.TrainID] = &s.Train
+		trips = append(trips, []string{strconv.FormatUint(uint64(s.TrainID), 10), serviceID, tripID, s.Train.Number})
+
+		type stop struct {
+			station            *models.Station
+			track              int // Номер пути, 0 — не указан
+			arrival, departure time.Time
+		}
+		path := []stop{{s.FromStation, s.TrackNumber, s.DepartureTime, s.DepartureTime}}
+		for _, rs := range s.Stops {
+			if rs.Station != nil {
+				track := 0
+				if rs.Track != nil {
+					track = rs.Track.Number
+				}
+				path = append(path, stop{rs.Station, track, rs.ArrivalTime, rs.DepartureTime})
+			}
+		}
+		arrivalTrack := 0
+		if s.ArrivalTrack != nil {
+			arrivalTrack = s.ArrivalTrack.Number
+		}
+		path = append(path, stop{s.ToStation, arrivalTrack, s.ArrivalTime, s.ArrivalTime})
+
+		for seq, p := range path {
+			stopID := gtfsStop(stops, p.station, p.track)
+			stopTimes = append(stopTimes, []string{
+				tripID, gtfsClock(p.arrival, day), gtfsClock(p.departure, day), stopID, strconv.Itoa(seq + 1),
+			})
+		}
+	}
+
+	archive := zip.NewWriter(w)
+
+	if err := writeGTFSFile(archive, "agency.txt",
+		[]string{"agency_id", "agency_name", "agency_url", "agency_timezone"},
+		[][]string{{"1", "Railway Dispatcher", "http://localhost", tzName}}); err != nil {
+		return err
+	}
+
+	var stopRows [][]string
+	for _, row := range stops {
+		stopRows = append(stopRows, row)
+	}
+	sort.Slice(stopRows, func(i, j int) bool { return stopRows[i][0] < stopRows[j][0] })
+	if err := writeGTFSFile(archive, "stops.txt",
+		[]string{"stop_id", "stop_code", "stop_name", "stop_lat", "stop_lon", "stop_timezone", "platform_code"}, stopRows); err != nil {
+		return err
+	}
+
+	var routeRows [][]string
+	for id, train := range routes {
+		routeRows = append(routeRows, []string{strconv.FormatUint(uint64(id), 10), "1", train.Number, "2"})
+	}
+	sort.Slice(routeRows, func(i, j int) bool { return routeRows[i][2] < routeRows[j][2] })
+	if err := writeGTFSFile(archive, "routes.txt",
+		[]string{"route_id", "agency_id", "route_short_name", "route_type"}, routeRows); err != nil {
+		return err
+	}
+
+	if err := writeGTFSFile(archive, "trips.txt",
+		[]string{"route_id", "service_id", "trip_id", "trip_short_name"}, trips); err != nil {
+		return err
+	}
+	if err := writeGTFSFile(archive, "stop_times.txt",
+		[]string{"trip_id", "arrival_time", "departure_time", "stop_id", "stop_sequence"}, stopTimes); err != nil {
+		return err
+	}
+
+	var dateRows [][]string
+	for id := range services {
+		dateRows = append(dateRows, []string{id, id, "1"})
+	}
+	sort.Slice(dateRows, func(i, j int) bool { return dateRows[i][0] < dateRows[j][0] })
+	if err := writeGTFSFile(archive, "calendar_dates.txt",
+		[]string{"service_id", "date", "exception_type"}, dateRows); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
@@ -0,0 +1,619 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/models"
+)
+
+// MaxImportRows ограничивает число рейсов в одном импорте.
+const MaxImportRows = 5000
+
+// ImportRow — рейс, собранный из строки CSV или поездки GTFS на одну дату,
+// и результат его проверки.
+type ImportRow struct {
+	Row           int        `json:"row"`           // Номер строки CSV или поездки в trips.txt
+	Ref           string     `json:"ref,omitempty"` // trip_id и дата для GTFS
+	TrainNumber   string     `json:"train_number"`
+	DepartureTime *time.Time `json:"departure_time,omitempty"`
+	ArrivalTime   *time.Time `json:"arrival_time,omitempty"`
+	ScheduleID    uint       `json:"schedule_id,omitempty"`
+	Error         string     `json:"error,omitempty"`
+
+	Schedule *models.Schedule `json:"-"`
+}
+
+func (r *ImportRow) fail(format string, args ...interface{}) {
+	r.Error = fmt.Sprintf(format, args...)
+}
+
+func (r *ImportRow) setSchedule(schedule *models.Schedule) {
+	r.Schedule = schedule
+	r.DepartureTime = &schedule.DepartureTime
+	r.ArrivalTime = &schedule.ArrivalTime
+}
+
+// importLookup сопоставляет коды станций и номера поездов из файла с записями
+// в базе, кэшируя результаты.
+type importLookup struct {
+	stations map[string]*models.Station
+	trains   map[string]*models.Train
+	tracks   map[string]*uint
+}
+
+func newImportLookup() *importLookup {
+	return &importLookup{
+		stations: make(map[string]*models.Station),
+		trains:   make(map[string]*models.Train),
+		tracks:   make(map[string]*uint),
+	}
+}
+
+// station ищет станцию по коду, а если не найдена — по названию.
+func (l *importLookup) station(code, name string) *models.Station {
+	key := code + "\x00" + name
+	if station, ok := l.stations[key]; ok {
+		return station
+	}
+	var station models.Station
+	err := database.DB.Where("code = ?", code).First(&station).Error
+	if err != nil && name != "" {
+		err = database.DB.Where("name = ?", name).First(&station).Error
+	}
+	if err != nil {
+		l.stations[key] = nil
+		return nil
+	}
+	l.stations[key] = &station
+	return &station
+}
+
+func (l *importLookup) train(number string) *models.Train {
+	if train, ok := l.trains[number]; ok {
+		return train
+	}
+	var train models.Train
+	if err := database.DB.Where("number = ?", number).First(&train).Error; err != nil {
+		l.trains[number] = nil
+		return nil
+	}
+	l.trains[number] = &train
+	return &train
+}
+
+// track возвращает путь станции по номеру или nil, если номер не задан.
+func (l *importLookup) track(stationID uint, number int) (*uint, error) {
+	if number <= 0 {
+		return nil, nil
+	}
+	key := fmt.Sprintf("%d:%d", stationID, number)
+	if id, ok := l.tracks[key]; ok {
+		if id == nil {
+			return nil, fmt.Errorf("путь %d не найден на станции", number)
+		}
+		return id, nil
+	}
+	var track models.Track
+	if err := database.DB.Where("station_id = ? AND number = ?", stationID, number).First(&track).Error; err != nil {
+		l.tracks[key] = nil
+		return nil, fmt.Errorf("путь %d не найден на станции", number)
+	}
+	l.tracks[key] = &track.ID
+	return &track.ID, nil
+}
+
+type csvTable struct {
+	rows  []map[string]string
+	lines []int
+}
+
+func readCSVTable(r io.Reader) (*csvTable, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("пустой файл или неверный формат CSV")
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], "\uFEFF")))
+	}
+
+	table := &csvTable{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(record) {
+				row[name] = strings.TrimSpace(record[i])
+			}
+		}
+		line, _ := reader.FieldPos(0)
+		table.rows = append(table.rows, row)
+		table.lines = append(table.lines, line)
+	}
+	return table, nil
+}
+
+func parseImportTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("неверный формат времени: %s", value)
+}
+
+// ParseScheduleCSV разбирает CSV с колонками train_number, from_station,
+// to_station (коды станций), departure_time, arrival_time и необязательными
+// track_number и arrival_track. Время без часового пояса берётся по станции
+// отправления.
+func ParseScheduleCSV(r io.Reader) ([]ImportRow, error) {
+	table, err := readCSVTable(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(table.rows) > MaxImportRows {
+		return nil, fmt.Errorf("файл содержит больше %d рейсов", MaxImportRows)
+	}
+
+	lookup := newImportLookup()
+	rows := make([]ImportRow, len(table.rows))
+	for i, record := range table.rows {
+		row := &rows[i]
+		row.Row = table.lines[i]
+		row.TrainNumber = record["train_number"]
+
+		train := lookup.train(record["train_number"])
+		if train == nil {
+			row.fail("поезд %q не найден", record["train_number"])
+			continue
+		}
+		from := lookup.station(record["from_station"], "")
+		to := lookup.station(record["to_station"], "")
+		if from == nil || to == nil {
+			row.fail("станция не найдена")
+			continue
+		}
+
+		loc := StationLocation(&from.ID)
+		departure, err := parseImportTime(record["departure_time"], loc)
+		if err != nil {
+			row.fail("%s", err.Error())
+			continue
+		}
+		arrival, err := parseImportTime(record["arrival_time"], StationLocation(&to.ID))
+		if err != nil {
+			row.fail("%s", err.Error())
+			continue
+		}
+
+		schedule := &models.Schedule{
+			TrainID:       train.ID,
+			DepartureTime: departure,
+			ArrivalTime:   arrival,
+			FromStationID: &from.ID,
+			ToStationID:   &to.ID,
+		}
+		if value := record["track_number"]; value != "" {
+			if schedule.TrackNumber, err = strconv.Atoi(value); err != nil {
+				row.fail("неверный номер пути: %s", value)
+				continue
+			}
+		}
+		if value := record["arrival_track"]; value != "" {
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				row.fail("неверный номер пути: %s", value)
+				continue
+			}
+			if schedule.ArrivalTrackID, err = lookup.track(to.ID, number); err != nil {
+				row.fail("%s", err.Error())
+				continue
+			}
+		}
+
+		row.setSchedule(schedule)
+	}
+
+	return rows, nil
+}
+
+type gtfsStopTime struct {
+	stopID    string
+	sequence  int
+	arrival   time.Duration
+	departure time.Duration
+}
+
+type gtfsService struct {
+	weekdays   [7]bool
+	start, end time.Time
+	loc        *time.Location
+	added      map[string]bool
+	removed    map[string]bool
+}
+
+func (s *gtfsService) activeOn(day time.Time) bool {
+	key := day.Format("20060102")
+	if s.removed[key] {
+		return false
+	}
+	if s.added[key] {
+		return true
+	}
+	return !day.Before(s.start) && !day.After(s.end) && s.weekdays[day.Weekday()]
+}
+
+func openGTFSTable(files map[string]*zip.File, name string, required bool) (*csvTable, error) {
+	file, ok := files[name]
+	if !ok {
+		if required {
+			return nil, fmt.Errorf("в архиве GTFS нет файла %s", name)
+		}
+		return &csvTable{}, nil
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	table, err := readCSVTable(rc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err.Error())
+	}
+	return table, nil
+}
+
+// parseGTFSTime разбирает время GTFS ЧЧ:ММ:СС, которое может превышать 24 часа
+// для поездок, переходящих через полночь.
+func parseGTFSTime(value string) (time.Duration, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("неверное время GTFS: %s", value)
+	}
+	var n [3]int
+	for i, part := range parts {
+		v, err := strconv.Atoi(part)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("неверное время GTFS: %s", value)
+		}
+		n[i] = v
+	}
+	return time.Duration(n[0])*time.Hour + time.Duration(n[1])*time.Minute + time.Duration(n[2])*time.Second, nil
+}
+
+// ParseGTFS разбирает архив GTFS (stops.txt, trips.txt, stop_times.txt,
+// calendar.txt и необязательные calendar_dates.txt, agency.txt) в рейсы: каждая
+// поездка порождает рейс на каждую дату её сервиса. Остановки сопоставляются
+// со станциями по stop_code (или stop_id), затем по названию; поезда — по
+// trip_short_name (или trip_id); номер пути берётся из platform_code.
+func ParseGTFS(archive *zip.Reader) ([]ImportRow, error) {
+	files := make(map[string]*zip.File)
+	for _, f := range archive.File {
+		name := f.Name
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+		files[name] = f
+	}
+
+	stops, err := openGTFSTable(files, "stops.txt", true)
+	if err != nil {
+		return nil, err
+	}
+	trips, err := openGTFSTable(files, "trips.txt", true)
+	if err != nil {
+		return nil, err
+	}
+	stopTimes, err := openGTFSTable(files, "stop_times.txt", true)
+	if err != nil {
+		return nil, err
+	}
+	calendar, err := openGTFSTable(files, "calendar.txt", false)
+	if err != nil {
+		return nil, err
+	}
+	calendarDates, err := openGTFSTable(files, "calendar_dates.txt", false)
+	if err != nil {
+		return nil, err
+	}
+	agency, err := openGTFSTable(files, "agency.txt", false)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := time.Local
+	if len(agency.rows) > 0 && agency.rows[0]["agency_timezone"] != "" {
+		if loc, err = time.LoadLocation(agency.rows[0]["agency_timezone"]); err != nil {
+			return nil, errors.New("неизвестный часовой пояс agency_timezone")
+		}
+	}
+
+	// Платформы (location_type 0 с parent_station) относятся к станции-родителю
+	stopRows := make(map[string]map[string]string)
+	for _, stop := range stops.rows {
+		stopRows[stop["stop_id"]] = stop
+	}
+	stationOf := func(stopID string) map[string]string {
+		stop := stopRows[stopID]
+		if stop != nil && stop["parent_station"] != "" && stopRows[stop["parent_station"]] != nil {
+			return stopRows[stop["parent_station"]]
+		}
+		return stop
+	}
+
+	services := make(map[string]*gtfsService)
+	for _, row := range calendar.rows {
+		service := &gtfsService{loc: loc, added: map[string]bool{}, removed: map[string]bool{}}
+		for i, day := range []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"} {
+			service.weekdays[i] = row[day] == "1"
+		}
+		if service.start, err = time.ParseInLocation("20060102", row["start_date"], loc); err != nil {
+			return nil, fmt.Errorf("calendar.txt: неверная дата %s", row["start_date"])
+		}
+		if service.end, err = time.ParseInLocation("20060102", row["end_date"], loc); err != nil {
+			return nil, fmt.Errorf("calendar.txt: неверная дата %s", row["end_date"])
+		}
+		services[row["service_id"]] = service
+	}
+	for _, row := range calendarDates.rows {
+		service := services[row["service_id"]]
+		if service == nil {
+			service = &gtfsService{loc: loc, added: map[string]bool{}, removed: map[string]bool{}}
+			services[row["service_id"]] = service
+		}
+		if row["exception_type"] == "1" {
+			service.added[row["date"]] = true
+		} else if row["exception_type"] == "2" {
+			service.removed[row["date"]] = true
+		}
+	}
+
+	timesByTrip := make(map[string][]gtfsStopTime)
+	for i, row := range stopTimes.rows {
+		st := gtfsStopTime{stopID: row["stop_id"]}
+		st.sequence, _ = strconv.Atoi(row["stop_sequence"])
+		if st.arrival, err = parseGTFSTime(row["arrival_time"]); err != nil {
+			return nil, fmt.Errorf("stop_times.txt, строка %d: %s", stopTimes.lines[i], err.Error())
+		}
+		if st.departure, err = parseGTFSTime(row["departure_time"]); err != nil {
+			return nil, fmt.Errorf("stop_times.txt, строка %d: %s", stopTimes.lines[i], err.Error())
+		}
+		timesByTrip[row["trip_id"]] = append(timesByTrip[row["trip_id"]], st)
+	}
+
+	lookup := newImportLookup()
+	var rows []ImportRow
+	for i, trip := range trips.rows {
+		tripID := trip["trip_id"]
+		number := trip["trip_short_name"]
+		if number == "" {
+			number = tripID
+		}
+		base := ImportRow{Row: trips.lines[i], Ref: tripID, TrainNumber: number}
+
+		times := timesByTrip[tripID]
+		sort.Slice(times, func(a, b int) bool { return times[a].sequence < times[b].sequence })
+		if len(times) < 2 {
+			base.fail("у поездки меньше двух остановок")
+			rows = append(rows, base)
+			continue
+		}
+
+		train := lookup.train(number)
+		if train == nil {
+			base.fail("поезд %q не найден", number)
+			rows = append(rows, base)
+			continue
+		}
+
+		stations := make([]*models.Station, len(times))
+		var missing string
+		for j, st := range times {
+			stop := stationOf(st.stopID)
+			if stop == nil {
+				missing = st.stopID
+				break
+			}
+			code := stop["stop_code"]
+			if code == "" {
+				code = stop["stop_id"]
+			}
+			if stations[j] = lookup.station(code, stop["stop_name"]); stations[j] == nil {
+				missing = code
+				break
+			}
+		}
+		if missing != "" {
+			base.fail("станция %q не найдена", missing)
+			rows = append(rows, base)
+			continue
+		}
+
+		platform := func(j int) int {
+			n, _ := strconv.Atoi(stopRows[times[j].stopID]["platform_code"])
+			return n
+		}
+
+		service := services[trip["service_id"]]
+		if service == nil {
+			base.fail("сервис %q не найден в calendar.txt", trip["service_id"])
+			rows = append(rows, base)
+			continue
+		}
+
+		for _, day := range service.dates() {
+			row := base
+			row.Ref = tripID + " " + day.Format("2006-01-02")
+
+			last := len(times) - 1
+			schedule := &models.Schedule{
+				TrainID:       train.ID,
+				TrackNumber:   platform(0),
+				DepartureTime: day.Add(times[0].departure),
+				ArrivalTime:   day.Add(times[last].arrival),
+				FromStationID: &stations[0].ID,
+				ToStationID:   &stations[last].ID,
+			}
+			if schedule.TrackNumber <= 0 {
+				row.fail("не указан путь отправления: у остановки %q нет platform_code", times[0].stopID)
+				rows = append(rows, row)
+				continue
+			}
+			if schedule.ArrivalTrackID, err = lookup.track(stations[last].ID, platform(last)); err != nil {
+				row.fail("%s", err.Error())
+				rows = append(rows, row)
+				continue
+			}
+
+			failed := false
+			for j := 1; j < last; j++ {
+				trackID, err := lookup.track(stations[j].ID, platform(j))
+				if err != nil {
+					row.fail("остановка %d: %s", j, err.Error())
+					failed = true
+					break
+				}
+				schedule.Stops = append(schedule.Stops, models.RouteStop{
+					Sequence:      j,
+					StationID:     stations[j].ID,
+					TrackID:       trackID,
+					ArrivalTime:   day.Add(times[j].arrival),
+					DepartureTime: day.Add(times[j].departure),
+					DwellMinutes:  int((times[j].departure - times[j].arrival).Minutes()),
+				})
+			}
+			if !failed {
+				row.setSchedule(schedule)
+			}
+			rows = append(rows, row)
+
+			if len(rows) > MaxImportRows {
+				return nil, fmt.Errorf("архив порождает больше %d рейсов", MaxImportRows)
+			}
+		}
+	}
+
+	return rows, nil
+}
+
+// dates возвращает даты, в которые действует сервис.
+func (s *gtfsService) dates() []time.Time {
+	seen := make(map[string]bool)
+	var days []time.Time
+	if !s.start.IsZero() {
+		for day := s.start; !day.After(s.end); day = day.AddDate(0, 0, 1) {
+			if s.activeOn(day) {
+				days = append(days, day)
+				seen[day.Format("20060102")] = true
+			}
+			if len(days) > MaxImportRows {
+				break
+			}
+		}
+	}
+	for key := range s.added {
+		if seen[key] || s.removed[key] {
+			continue
+		}
+		if day, err := time.ParseInLocation("20060102", key, s.loc); err == nil {
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// ValidateImportBatch проверяет рейсы импорта друг с другом: они ещё не в базе,
+// поэтому ScheduleValidator не видит их взаимных пересечений по поезду и путям.
+func ValidateImportBatch(rows []ImportRow) {
+	var valid []*ImportRow
+	for i := range rows {
+		if rows[i].Error == "" && rows[i].Schedule != nil {
+			valid = append(valid, &rows[i])
+		}
+	}
+	sort.SliceStable(valid, func(i, j int) bool {
+		return valid[i].Schedule.DepartureTime.Before(valid[j].Schedule.DepartureTime)
+	})
+
+	rules := LoadMaintenanceRules()
+	margin := rules.Max()
+	if TrainTurnaround > margin {
+		margin = TrainTurnaround
+	}
+
+	for i, a := range valid {
+		for _, b := range valid[i+1:] {
+			if b.Schedule.DepartureTime.After(a.Schedule.ArrivalTime.Add(margin)) {
+				break
+			}
+			if b.Error != "" {
+				continue
+			}
+			if err := batchConflict(a.Schedule, b.Schedule, rules); err != nil {
+				b.fail("%s другим рейсом импорта (строка %d)", err.Error(), a.Row)
+			}
+		}
+	}
+}
+
+// Коллизии между рейсами, сохраняемыми вместе.
+var (
+	errBatchTrainBusy = errors.New("поезд занят")
+	errBatchTrackBusy = errors.New("путь занят")
+)
+
+// batchConflict проверяет рейс b на коллизию с отправляющимся не позже рейсом
+// a того же пакета. Пути сравниваются так же, как при проверке по базе: путь
+// отправления занят в момент отправления, путь прибытия — в момент прибытия.
+func batchConflict(a, b *models.Schedule, rules *MaintenanceRules) error {
+	if a.TrainID == b.TrainID && b.DepartureTime.Before(a.ArrivalTime.Add(TrainTurnaround)) {
+		return errBatchTrainBusy
+	}
+
+	for _, sa := range scheduleTrackScopes(a) {
+		for _, sb := range scheduleTrackScopes(b) {
+			if !sameTrack(sa, sb) {
+				continue
+			}
+			prev, next, gap := a, b, sb.from.Sub(sa.from)
+			if gap < 0 {
+				prev, next, gap = b, a, -gap
+			}
+			if gap < rules.Between(sa.trackID, sa.stationID, prev, next) {
+				return errBatchTrackBusy
+			}
+		}
+	}
+
+	return nil
+}
+
+// sameTrack сообщает, что области относятся к одному пути.
+func sameTrack(a, b trackScope) bool {
+	if a.trackID != nil || b.trackID != nil {
+		return a.trackID != nil && b.trackID != nil && *a.trackID == *b.trackID
+	}
+	return a.trackNumber == b.trackNumber && a.stationID != nil && b.stationID != nil && *a.stationID == *b.stationID
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"railway-dispatcher/internal/database"
@@ -10,112 +11,193 @@ import (
 	"gorm.io/gorm"
 )
 
-const MaintenanceWindow = 20 * time.Minute
-
-type ScheduleValidator struct{}
+// ScheduleValidator проверяет коллизии путей. Нулевое значение читает через
+// общее соединение; внутри транзакции бронирования db указывает на неё.
+// Рейсы exclude не считаются занимающими пути: это рейсы серии, которые
+// переносятся вместе с проверяемым.
+type ScheduleValidator struct {
+	db      *gorm.DB
+	exclude []uint
+}
 
-func (v *ScheduleValidator) ValidateSchedule(schedule *models.Schedule) error {
-	var conflicting models.Schedule
-	err := database.DB.Where(
-		"track_number = ? AND id != ? AND deleted_at IS NULL AND ((departure_time <= ? AND arrival_time >= ?) OR (departure_time <= ? AND arrival_time >= ?) OR (departure_time >= ? AND arrival_time <= ?))",
-		schedule.TrackNumber,
-		schedule.ID,
-		schedule.DepartureTime, schedule.DepartureTime,
-		schedule.ArrivalTime, schedule.ArrivalTime,
-		schedule.DepartureTime, schedule.ArrivalTime,
-	).First(&conflicting).Error
-
-	if err == nil {
-		return errors.New("коллизия: путь уже занят в указанное время")
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return err
+func (v *ScheduleValidator) conn() *gorm.DB {
+	if v.db != nil {
+		return v.db
 	}
+	return database.DB
+}
 
-	var beforeSchedule, afterSchedule models.Schedule
+// Excluding возвращает валидатор, не учитывающий рейсы ids.
+func (v *ScheduleValidator) Excluding(ids []uint) *ScheduleValidator {
+	return &ScheduleValidator{db: v.db, exclude: ids}
+}
 
-	database.DB.Where(
-		"track_number = ? AND id != ? AND arrival_time <= ? AND deleted_at IS NULL",
-		schedule.TrackNumber,
-		schedule.ID,
-		schedule.DepartureTime,
-	).Order("arrival_time DESC").First(&beforeSchedule)
+// excludedIDs возвращает рейсы, не участвующие в проверке рейса id.
+func excludedIDs(exclude []uint, id uint) []uint {
+	return append([]uint{id}, exclude...)
+}
 
-	if beforeSchedule.ID != 0 {
-		gap := schedule.DepartureTime.Sub(beforeSchedule.ArrivalTime)
-		if gap < MaintenanceWindow {
-			return errors.New("нарушение тех. окна: требуется минимум 20 минут после предыдущего рейса")
-		}
+// trackEndpoint — условие выборки рейсов, занимающих путь, и столбец с
+// моментом, в который они его занимают.
+type trackEndpoint struct {
+	where  string
+	args   []interface{}
+	column string
+}
+
+// trackScope описывает путь и интервал [from, to], в который его занимает
+// проверяемый рейс. Рейс занимает путь отправления в момент отправления, путь
+// прибытия — в момент прибытия, путь остановки — на время стоянки.
+type trackScope struct {
+	endpoints   []trackEndpoint
+	trackID     *uint // Путь станции, nil для рейсов без привязки к путям
+	stationID   *uint
+	trackNumber int
+	from, to    time.Time
+}
+
+func stationTrackScope(id uint, from, to time.Time) trackScope {
+	return trackScope{
+		endpoints: []trackEndpoint{
+			{"departure_track_id = ?", []interface{}{id}, "departure_time"},
+			{"arrival_track_id = ?", []interface{}{id}, "arrival_time"},
+		},
+		trackID: &id,
+		from:    from,
+		to:      to,
+	}
+}
+
+func scheduleTrackScopes(schedule *models.Schedule) []trackScope {
+	var scopes []trackScope
+
+	if schedule.DepartureTrackID != nil {
+		scopes = append(scopes, stationTrackScope(*schedule.DepartureTrackID, schedule.DepartureTime, schedule.DepartureTime))
+	}
+	if schedule.ArrivalTrackID != nil {
+		scopes = append(scopes, stationTrackScope(*schedule.ArrivalTrackID, schedule.ArrivalTime, schedule.ArrivalTime))
 	}
 
-	database.DB.Where(
-		"track_number = ? AND id != ? AND departure_time >= ? AND deleted_at IS NULL",
-		schedule.TrackNumber,
-		schedule.ID,
-		schedule.ArrivalTime,
-	).Order("departure_time ASC").First(&afterSchedule)
-
-	if afterSchedule.ID != 0 {
-		gap := afterSchedule.DepartureTime.Sub(schedule.ArrivalTime)
-		if gap < MaintenanceWindow {
-			return errors.New("нарушение тех. окна: требуется минимум 20 минут перед следующим рейсом")
+	if len(scopes) == 0 {
+		// Рейсы без привязки к путям станций сравниваются по номеру пути в пределах станции отправления
+		endpoint := trackEndpoint{
+			where:  "track_number = ? AND from_station_id IS NULL AND departure_track_id IS NULL AND arrival_track_id IS NULL",
+			args:   []interface{}{schedule.TrackNumber},
+			column: "departure_time",
+		}
+		if schedule.FromStationID != nil {
+			endpoint.where = "track_number = ? AND from_station_id = ? AND departure_track_id IS NULL AND arrival_track_id IS NULL"
+			endpoint.args = append(endpoint.args, *schedule.FromStationID)
 		}
+		scopes = append(scopes, trackScope{
+			endpoints:   []trackEndpoint{endpoint},
+			stationID:   schedule.FromStationID,
+			trackNumber: schedule.TrackNumber,
+			from:        schedule.DepartureTime,
+			to:          schedule.DepartureTime,
+		})
 	}
 
-	return nil
+	return scopes
 }
 
-type TimeSlot struct {
-	TrackNumber   int       `json:"track_number"`
-	DepartureTime time.Time `json:"departure_time"`
-	ArrivalTime   time.Time `json:"arrival_time"`
+// trackOccupant — рейс, занимающий путь в момент at.
+type trackOccupant struct {
+	schedule models.Schedule
+	at       time.Time
 }
 
-func (v *ScheduleValidator) FindAlternativeSlots(trackNumber int, duration time.Duration, nearTime time.Time) []TimeSlot {
-	var slots []TimeSlot
-	var schedules []models.Schedule
+// occupants возвращает рейсы, кроме exclude, занимающие путь области в [from, to].
+func (s trackScope) occupants(db *gorm.DB, exclude []uint, from, to time.Time) ([]trackOccupant, error) {
+	var occupants []trackOccupant
+	for _, endpoint := range s.endpoints {
+		var schedules []models.Schedule
+		err := db.Where(endpoint.where, endpoint.args...).
+			Where("id NOT IN ? AND status != ? AND deleted_at IS NULL AND "+endpoint.column+" BETWEEN ? AND ?",
+				exclude, models.StatusCancelled, from, to).
+			Find(&schedules).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, schedule := range schedules {
+			at := schedule.DepartureTime
+			if endpoint.column == "arrival_time" {
+				at = schedule.ArrivalTime
+			}
+			occupants = append(occupants, trackOccupant{schedule, at})
+		}
+	}
+	return occupants, nil
+}
 
-	database.DB.Where("track_number = ? AND deleted_at IS NULL", trackNumber).
-		Order("departure_time ASC").Find(&schedules)
+func (v *ScheduleValidator) ValidateSchedule(schedule *models.Schedule) error {
+	rules := loadMaintenanceRules(v.conn())
+	for _, scope := range scheduleTrackScopes(schedule) {
+		if err := v.validateTrackScope(scope, schedule, rules); err != nil {
+			return err
+		}
+	}
+	return v.validateStopTracks(schedule, rules)
+}
 
-	now := time.Now()
-	if nearTime.Before(now) {
-		nearTime = now
+// validateTrackScope проверяет, что путь области свободен в интервале scope
+// с тех. окнами до и после него.
+func (v *ScheduleValidator) validateTrackScope(scope trackScope, schedule *models.Schedule, rules *MaintenanceRules) error {
+	db := v.conn()
+	if err := validateClosures(db, scope); err != nil {
+		return err
 	}
 
-	searchStart := nearTime
+	margin := rules.Max()
+	occupants, err := scope.occupants(db, excludedIDs(v.exclude, schedule.ID), scope.from.Add(-margin), scope.to.Add(margin))
+	if err != nil {
+		return err
+	}
 
-	for i := 0; i <= len(schedules) && len(slots) < 3; i++ {
-		var slotStart, slotEnd time.Time
+	for _, o := range occupants {
+		if !o.at.Before(scope.from) && !o.at.After(scope.to) {
+			return errors.New("коллизия: путь уже занят в указанное время")
+		}
+	}
 
-		if i == 0 {
-			if len(schedules) == 0 {
-				slotStart = searchStart
-				slotEnd = searchStart.Add(24 * time.Hour)
-			} else {
-				slotStart = searchStart
-				slotEnd = schedules[0].DepartureTime.Add(-MaintenanceWindow)
+	for _, o := range occupants {
+		if o.at.Before(scope.from) {
+			if window := rules.Between(scope.trackID, scope.stationID, &o.schedule, schedule); scope.from.Sub(o.at) < window {
+				return fmt.Errorf("нарушение тех. окна: требуется минимум %d минут после предыдущего рейса", int(window.Minutes()))
 			}
-		} else if i == len(schedules) {
-			slotStart = schedules[i-1].ArrivalTime.Add(MaintenanceWindow)
-			slotEnd = slotStart.Add(24 * time.Hour)
-		} else {
-			slotStart = schedules[i-1].ArrivalTime.Add(MaintenanceWindow)
-			slotEnd = schedules[i].DepartureTime.Add(-MaintenanceWindow)
+		} else if window := rules.Between(scope.trackID, scope.stationID, schedule, &o.schedule); o.at.Sub(scope.to) < window {
+			return fmt.Errorf("нарушение тех. окна: требуется минимум %d минут перед следующим рейсом", int(window.Minutes()))
 		}
+	}
 
-		if slotStart.Before(now) {
-			slotStart = now.Add(MaintenanceWindow)
-		}
+	return nil
+}
 
-		availableDuration := slotEnd.Sub(slotStart)
-		if availableDuration >= duration {
-			slots = append(slots, TimeSlot{
-				TrackNumber:   trackNumber,
-				DepartureTime: slotStart,
-				ArrivalTime:   slotStart.Add(duration),
-			})
-		}
-	}
+type TimeSlot struct {
+	TrackNumber      int       `json:"track_number"`
+	DepartureTrackID *uint     `json:"departure_track_id,omitempty"`
+	ArrivalTrackID   *uint     `json:"arrival_track_id,omitempty"`
+	DepartureTime    time.Time `json:"departure_time"`
+	ArrivalTime      time.Time `json:"arrival_time"`
+	OffsetMinutes    int       `json:"offset_minutes"` // Сдвиг относительно желаемого времени
+
+	preferred bool
+}
 
+// FindAlternativeSlots подбирает ближайшие к nearTime свободные слоты для рейса,
+// отклонённого из-за коллизии.
+func (v *ScheduleValidator) FindAlternativeSlots(schedule *models.Schedule, duration time.Duration, nearTime time.Time) []TimeSlot {
+	slots, _ := (&SlotFinder{}).Find(SlotQuery{
+		TrainID:          schedule.TrainID,
+		FromStationID:    schedule.FromStationID,
+		ToStationID:      schedule.ToStationID,
+		DepartureTrackID: schedule.DepartureTrackID,
+		ArrivalTrackID:   schedule.ArrivalTrackID,
+		TrackNumber:      schedule.TrackNumber,
+		Near:             nearTime,
+		Duration:         duration,
+		Limit:            5,
+		ExcludeID:        schedule.ID,
+	})
 	return slots
 }
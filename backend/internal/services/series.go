@@ -0,0 +1,91 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// SeriesScope определяет, к каким рейсам серии применяется изменение.
+type SeriesScope string
+
+const (
+	ScopeThis      SeriesScope = "this"      // Только выбранный рейс
+	ScopeFollowing SeriesScope = "following" // Выбранный и все последующие
+	ScopeSeries    SeriesScope = "series"    // Вся серия
+)
+
+func ParseSeriesScope(value string) (SeriesScope, error) {
+	switch SeriesScope(value) {
+	case "", ScopeThis:
+		return ScopeThis, nil
+	case ScopeFollowing, ScopeSeries:
+		return SeriesScope(value), nil
+	default:
+		return "", fmt.Errorf("неверная область изменения: %s", value)
+	}
+}
+
+// SeriesRootID возвращает идентификатор исходного рейса серии.
+func SeriesRootID(schedule *models.Schedule) uint {
+	if schedule.ParentID != nil {
+		return *schedule.ParentID
+	}
+	return schedule.ID
+}
+
+// SeriesMembers возвращает рейсы серии, затронутые изменением в области scope,
+// в порядке отправления.
+func SeriesMembers(schedule *models.Schedule, scope SeriesScope) ([]models.Schedule, error) {
+	if scope == ScopeThis {
+		return []models.Schedule{*schedule}, nil
+	}
+
+	rootID := SeriesRootID(schedule)
+	query := database.DB.Preload("Stops", func(db *gorm.DB) *gorm.DB { return db.Order("sequence ASC") }).
+		Where("(id = ? OR parent_id = ?)", rootID, rootID)
+	if scope == ScopeFollowing {
+		query = query.Where("departure_time >= ?", schedule.DepartureTime)
+	}
+
+	var members []models.Schedule
+	if err := query.Order("departure_time ASC").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// ValidateSeriesMembers проверяет перенесённые рейсы серии друг против друга
+// на их новом времени: в базе они ещё стоят на прежнем и в проверках коллизий
+// не участвуют. Возвращает первый рейс с коллизией.
+func ValidateSeriesMembers(members []models.Schedule) (*models.Schedule, error) {
+	sorted := make([]*models.Schedule, len(members))
+	for i := range members {
+		sorted[i] = &members[i]
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].DepartureTime.Before(sorted[j].DepartureTime)
+	})
+
+	rules := LoadMaintenanceRules()
+	margin := rules.Max()
+	if TrainTurnaround > margin {
+		margin = TrainTurnaround
+	}
+
+	for i, a := range sorted {
+		for _, b := range sorted[i+1:] {
+			if b.DepartureTime.After(a.ArrivalTime.Add(margin)) {
+				break
+			}
+			if err := batchConflict(a, b, rules); err != nil {
+				return b, fmt.Errorf("%s рейсом серии #%d", err.Error(), a.ID)
+			}
+		}
+	}
+	return nil, nil
+}
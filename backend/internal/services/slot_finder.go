@@ -0,0 +1,269 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/models"
+)
+
+// SlotQuery описывает искомый рейс: поезд, станции, предпочтительные пути и
+// желаемое время отправления. Слоты ищутся в окне Window до и после Near.
+type SlotQuery struct {
+	TrainID          uint
+	FromStationID    *uint
+	ToStationID      *uint
+	DepartureTrackID *uint
+	ArrivalTrackID   *uint
+	TrackNumber      int
+	Near             time.Time
+	Duration         time.Duration
+	Window           time.Duration
+	Limit            int
+	ExcludeID        uint // Редактируемый рейс не считается занятым
+}
+
+type SlotFinder struct{}
+
+type interval struct {
+	start, end time.Time
+}
+
+// trackOption — путь станции, на который можно поставить поезд; nil ID
+// означает станцию без описанных путей (сравнение по номеру пути).
+type trackOption struct {
+	id        *uint
+	number    int
+	preferred bool
+}
+
+// Find ищет свободные слоты на всех путях станций отправления и прибытия,
+// которые вмещают состав. Слот учитывает тех. окно путей, оборот поезда и
+// минимальное время хода по перегону. Результат упорядочен по удалённости от
+// желаемого времени в обе стороны, при равенстве — предпочтительные пути раньше.
+func (f *SlotFinder) Find(q SlotQuery) ([]TimeSlot, error) {
+	if q.Duration <= 0 {
+		return nil, errors.New("продолжительность рейса должна быть положительной")
+	}
+	if q.Window <= 0 {
+		q.Window = 24 * time.Hour
+	}
+	if q.Limit <= 0 {
+		q.Limit = 10
+	}
+
+	var train models.Train
+	if err := database.DB.First(&train, q.TrainID).Error; err != nil {
+		return nil, errors.New("поезд не найден")
+	}
+
+	duration := q.Duration
+	if q.FromStationID != nil && q.ToStationID != nil {
+		segment, err := FindSegment(*q.FromStationID, *q.ToStationID)
+		if err != nil {
+			return nil, err
+		}
+		if segment != nil {
+			minDuration, err := MinTravelTime(segment, &train)
+			if err != nil {
+				return nil, err
+			}
+			if duration < minDuration {
+				duration = minDuration.Round(time.Minute)
+				if duration < minDuration {
+					duration += time.Minute
+				}
+			}
+		}
+	}
+
+	from := q.Near.Add(-q.Window)
+	if now := time.Now(); from.Before(now) {
+		from = now
+	}
+	to := q.Near.Add(q.Window).Add(duration)
+	latest := to.Add(-duration)
+	if latest.Before(from) {
+		return nil, nil
+	}
+
+	// Занятость путей и поезда приведена к запрещённым моментам отправления:
+	// слот — время отправления вне всех интервалов занятости.
+	trainBusy := f.trainBusy(q, duration, from, to)
+	rules := LoadMaintenanceRules()
+
+	var slots []TimeSlot
+	for _, dep := range trackOptions(q.FromStationID, q.DepartureTrackID, q.TrackNumber, &train) {
+		for _, arr := range trackOptions(q.ToStationID, q.ArrivalTrackID, 0, &train) {
+			probe := models.Schedule{
+				ID:               q.ExcludeID,
+				TrainID:          q.TrainID,
+				TrackNumber:      dep.number,
+				DepartureTrackID: dep.id,
+				ArrivalTrackID:   arr.id,
+				FromStationID:    q.FromStationID,
+				DepartureTime:    from,
+				ArrivalTime:      from.Add(duration),
+			}
+			busy := append(trackBusy(&probe, from, to, rules), trainBusy...)
+
+			for _, gap := range freeGaps(busy, from, latest) {
+				departure, ok := closestStart(gap, q.Near)
+				if !ok {
+					continue
+				}
+				slots = append(slots, TimeSlot{
+					TrackNumber:      dep.number,
+					DepartureTrackID: dep.id,
+					ArrivalTrackID:   arr.id,
+					DepartureTime:    departure,
+					ArrivalTime:      departure.Add(duration),
+					OffsetMinutes:    int(departure.Sub(q.Near).Minutes()),
+					preferred:        dep.preferred && arr.preferred,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(slots, func(i, j int) bool {
+		di, dj := absDuration(slots[i].DepartureTime.Sub(q.Near)), absDuration(slots[j].DepartureTime.Sub(q.Near))
+		if di != dj {
+			return di < dj
+		}
+		if slots[i].preferred != slots[j].preferred {
+			return slots[i].preferred
+		}
+		return slots[i].DepartureTime.Before(slots[j].DepartureTime)
+	})
+
+	if len(slots) > q.Limit {
+		slots = slots[:q.Limit]
+	}
+	return slots, nil
+}
+
+func trackOptions(stationID, preferredID *uint, trackNumber int, train *models.Train) []trackOption {
+	if stationID == nil {
+		return []trackOption{{id: preferredID, number: trackNumber, preferred: true}}
+	}
+
+	tracks := fittingTracks(*stationID, preferredID, train)
+	if len(tracks) == 0 {
+		if preferredID != nil {
+			return nil
+		}
+		return []trackOption{{number: trackNumber, preferred: true}}
+	}
+
+	options := make([]trackOption, 0, len(tracks))
+	for _, track := range tracks {
+		id := track.ID
+		options = append(options, trackOption{
+			id:        &id,
+			number:    track.Number,
+			preferred: preferredID == nil || *preferredID == id,
+		})
+	}
+	return options
+}
+
+// trackBusy возвращает запрещённые моменты отправления рейса probe из-за
+// занятости его путей в [from, to]: рейс занимает путь отправления в момент
+// отправления, путь прибытия — в момент прибытия, и вокруг чужого рейса на
+// пути действуют тех. окна в обе стороны. Закрытия путей тоже учитываются.
+// Времена probe задают лишь смещение прибытия относительно отправления.
+func trackBusy(probe *models.Schedule, from, to time.Time, rules *MaintenanceRules) []interval {
+	var busy []interval
+	margin := rules.Max()
+	for _, scope := range scheduleTrackScopes(probe) {
+		offset := scope.from.Sub(probe.DepartureTime)
+		occupants, _ := scope.occupants(database.DB, []uint{probe.ID}, from.Add(-margin), to.Add(margin))
+		for _, o := range occupants {
+			busy = append(busy, interval{
+				o.at.Add(-rules.Between(scope.trackID, scope.stationID, probe, &o.schedule) - offset),
+				o.at.Add(rules.Between(scope.trackID, scope.stationID, &o.schedule, probe) - offset),
+			})
+		}
+		for _, closure := range scopeClosures(database.DB, scope, from, to) {
+			busy = append(busy, interval{closure.StartTime.Add(-offset), closure.EndTime.Add(-offset)})
+		}
+	}
+	return busy
+}
+
+// trainBusy возвращает запрещённые моменты отправления рейса длительностью
+// duration из-за других рейсов поезда с учётом времени на оборот.
+func (f *SlotFinder) trainBusy(q SlotQuery, duration time.Duration, from, to time.Time) []interval {
+	var schedules []models.Schedule
+	database.DB.Where(
+		"train_id = ? AND id != ? AND status != ? AND deleted_at IS NULL AND departure_time < ? AND arrival_time > ?",
+		q.TrainID, q.ExcludeID, models.StatusCancelled, to.Add(TrainTurnaround), from.Add(-TrainTurnaround),
+	).Find(&schedules)
+
+	busy := make([]interval, 0, len(schedules))
+	for _, s := range schedules {
+		busy = append(busy, interval{s.DepartureTime.Add(-TrainTurnaround - duration), s.ArrivalTime.Add(TrainTurnaround)})
+	}
+	return busy
+}
+
+// freeGaps возвращает промежутки [from, to], не пересекающиеся с busy.
+func freeGaps(busy []interval, from, to time.Time) []interval {
+	sort.Slice(busy, func(i, j int) bool { return busy[i].start.Before(busy[j].start) })
+
+	var gaps []interval
+	cursor := from
+	for _, b := range busy {
+		if b.start.After(cursor) {
+			end := b.start
+			if end.After(to) {
+				end = to
+			}
+			if end.After(cursor) {
+				gaps = append(gaps, interval{cursor, end})
+			}
+		}
+		if b.end.After(cursor) {
+			cursor = b.end
+		}
+		if !cursor.Before(to) {
+			return gaps
+		}
+	}
+	gaps = append(gaps, interval{cursor, to})
+	return gaps
+}
+
+// closestStart выбирает в промежутке время отправления, ближайшее к near,
+// с точностью до минуты.
+func closestStart(gap interval, near time.Time) (time.Time, bool) {
+	latest := gap.end
+
+	start := near
+	if start.Before(gap.start) {
+		start = gap.start
+	}
+	if start.After(latest) {
+		start = latest
+	}
+
+	if rounded := start.Truncate(time.Minute); !rounded.Equal(start) {
+		start = rounded.Add(time.Minute)
+		if start.After(latest) {
+			start = rounded
+		}
+	}
+	if start.Before(gap.start) || start.After(latest) {
+		return time.Time{}, false
+	}
+	return start, true
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/models"
+)
+
+type scheduleClock struct {
+	ID                  uint
+	Status              models.ScheduleStatus
+	DepartureTime       time.Time
+	ArrivalTime         time.Time
+	ActualDepartureTime *time.Time
+	ActualArrivalTime   *time.Time
+	DelayMinutes        int
+}
+
+// StatusEngine переводит рейсы Scheduled → InProgress → Completed по серверным
+// часам и записывает каждый переход в журнал аудита.
+type StatusEngine struct {
+	Interval time.Duration
+}
+
+func (e *StatusEngine) Run(ctx context.Context) {
+	e.Tick(time.Now())
+
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			e.Tick(now)
+		}
+	}
+}
+
+func (e *StatusEngine) Tick(now time.Time) {
+	// Читаем в отдельную структуру, чтобы AfterFind не подменил сохранённый статус
+	var rows []scheduleClock
+	err := database.DB.Model(&models.Schedule{}).Where(
+		"status IN ? AND departure_time <= ?",
+		[]models.ScheduleStatus{models.StatusScheduled, models.StatusInProgress}, now,
+	).Find(&rows).Error
+	if err != nil {
+		log.Printf("Ошибка обновления статусов рейсов: %v", err)
+		return
+	}
+
+	for _, row := range rows {
+		schedule := models.Schedule{
+			Status:              row.Status,
+			DepartureTime:       row.DepartureTime,
+			ArrivalTime:         row.ArrivalTime,
+			ActualDepartureTime: row.ActualDepartureTime,
+			ActualArrivalTime:   row.ActualArrivalTime,
+			DelayMinutes:        row.DelayMinutes,
+		}
+		next := schedule.EffectiveStatus(now)
+		if next == row.Status {
+			continue
+		}
+
+		result := database.DB.Model(&models.Schedule{}).
+			Where("id = ? AND status = ?", row.ID, row.Status).
+			Update("status", next)
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		createSystemAuditLog(models.ActionStatus, models.EntitySchedule, row.ID,
+			map[string]interface{}{"status": row.Status},
+			map[string]interface{}{"status": next, "at": now},
+		)
+	}
+}
+
+// createSystemAuditLog записывает действие, выполненное сервером без участия пользователя.
+func createSystemAuditLog(action models.AuditAction, entity models.AuditEntity, entityID uint, oldVal, newVal interface{}) {
+	audit := models.NewAuditLog(action, entity, entityID, oldVal, newVal)
+	audit.IP = "system"
+	database.DB.Create(audit)
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/models"
+	"railway-dispatcher/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidRefreshToken = errors.New("недействительный токен обновления")
+
+// TokenPair — ответ на вход и обновление: короткоживущий токен доступа и
+// токен обновления, который можно предъявить один раз.
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // Время жизни токена доступа, с
+}
+
+// SessionInfo описывает клиента, которому выдаются токены.
+type SessionInfo struct {
+	IP        string
+	UserAgent string
+}
+
+func newFamilyID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// IssueTokens выдаёт пару токенов при входе, открывая новое семейство токенов
+// обновления.
+func IssueTokens(user *models.User, session SessionInfo) (*TokenPair, error) {
+	family, err := newFamilyID()
+	if err != nil {
+		return nil, err
+	}
+	pair, _, err := issueTokens(database.DB, user, family, session)
+	return pair, err
+}
+
+func issueTokens(db *gorm.DB, user *models.User, family string, session SessionInfo) (*TokenPair, *models.RefreshToken, error) {
+	access, err := utils.GenerateToken(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	refresh, hash, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	record := &models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		FamilyID:  family,
+		ExpiresAt: time.Now().Add(utils.RefreshTokenTTL),
+		IP:        session.IP,
+		UserAgent: session.UserAgent,
+	}
+	if err := db.Create(record).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int(utils.AccessTokenTTL.Seconds()),
+	}, record, nil
+}
+
+// RotateRefreshToken обменивает токен обновления на новую пару токенов.
+// Предъявленный токен отзывается. Повторное предъявление уже отозванного
+// токена означает его утечку, поэтому отзывается всё семейство. Пользователю
+// без обязательной для его роли 2FA токены не выдаются.
+func RotateRefreshToken(raw string, session SessionInfo) (*models.User, *TokenPair, error) {
+	var user models.User
+	var pair *TokenPair
+	reused := false
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var token models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", utils.HashToken(raw)).First(&token).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		now := time.Now()
+		if token.RevokedAt != nil {
+			reused = true
+			return tx.Model(&models.RefreshToken{}).
+				Where("family_id = ? AND revoked_at IS NULL", token.FamilyID).
+				Update("revoked_at", now).Error
+		}
+		if now.After(token.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		if err := tx.First(&user, token.UserID).Error; err != nil || !user.IsActive() {
+			return ErrInvalidRefreshToken
+		}
+		// Сеансы, открытые до того, как 2FA стала обязательной для роли, не
+		// продлеваются: пользователь должен войти заново и подключить её
+		if TwoFactorRequired(user.Role) && !user.TOTPEnabled {
+			return ErrInvalidRefreshToken
+		}
+
+		var next *models.RefreshToken
+		var err error
+		if pair, next, err = issueTokens(tx, &user, token.FamilyID, session); err != nil {
+			return err
+		}
+		return tx.Model(&token).Updates(map[string]interface{}{
+			"revoked_at":     now,
+			"replaced_by_id": next.ID,
+		}).Error
+	})
+	if err == nil && reused {
+		err = ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &user, pair, nil
+}
+
+// RevokeRefreshToken отзывает семейство токена обновления пользователя (выход
+// из одного сеанса). Неизвестный токен не считается ошибкой.
+func RevokeRefreshToken(raw string, userID uint) error {
+	var token models.RefreshToken
+	if err := database.DB.Where("token_hash = ? AND user_id = ?", utils.HashToken(raw), userID).First(&token).Error; err != nil {
+		return nil
+	}
+	return database.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", token.FamilyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeUserTokens отзывает все токены пользователя: увеличивает версию
+// токенов, из-за чего выданные токены доступа перестают приниматься, и
+// отзывает все токены обновления. Версия записи тоже увеличивается, чтобы
+// сохранение прочитанного раньше пользователя не вернуло старую версию токенов.
+func RevokeUserTokens(db *gorm.DB, userID uint) error {
+	if err := db.Model(&models.User{}).Unscoped().Where("id = ?", userID).
+		UpdateColumns(map[string]interface{}{
+			"token_version": gorm.Expr("token_version + 1"),
+			"version":       gorm.Expr("version + 1"),
+		}).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
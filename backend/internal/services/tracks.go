@@ -0,0 +1,105 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ResolveTracks привязывает рейс к путям станций: проверяет, что указанные пути
+// принадлежат станциям отправления и прибытия, а при их отсутствии ищет путь
+// с номером TrackNumber на станции отправления.
+func ResolveTracks(schedule *models.Schedule) error {
+	if schedule.DepartureTrackID != nil {
+		track, err := loadStationTrack(*schedule.DepartureTrackID, schedule.FromStationID)
+		if err != nil {
+			return err
+		}
+		schedule.TrackNumber = track.Number
+	} else if schedule.FromStationID != nil && schedule.TrackNumber > 0 {
+		var track models.Track
+		err := database.DB.Where("station_id = ? AND number = ?", *schedule.FromStationID, schedule.TrackNumber).First(&track).Error
+		if err == nil {
+			schedule.DepartureTrackID = &track.ID
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+
+	if schedule.ArrivalTrackID != nil {
+		if _, err := loadStationTrack(*schedule.ArrivalTrackID, schedule.ToStationID); err != nil {
+			return err
+		}
+	}
+
+	if schedule.TrackNumber <= 0 {
+		return errors.New("не указан путь отправления")
+	}
+
+	return nil
+}
+
+func loadStationTrack(trackID uint, stationID *uint) (*models.Track, error) {
+	var track models.Track
+	if err := database.DB.First(&track, trackID).Error; err != nil {
+		return nil, errors.New("путь не найден")
+	}
+	if stationID == nil || *stationID != track.StationID {
+		return nil, errors.New("путь не принадлежит станции рейса")
+	}
+	return &track, nil
+}
+
+func TrackFits(track *models.Track, train *models.Train) bool {
+	return track.LengthWagons == 0 || train.WagonCount <= track.LengthWagons
+}
+
+func (v *ScheduleValidator) ValidateTrackCapacity(schedule *models.Schedule) error {
+	trackIDs := []*uint{schedule.DepartureTrackID, schedule.ArrivalTrackID}
+	for _, stop := range schedule.Stops {
+		trackIDs = append(trackIDs, stop.TrackID)
+	}
+
+	var train models.Train
+	loaded := false
+	for _, trackID := range trackIDs {
+		if trackID == nil {
+			continue
+		}
+		if !loaded {
+			if err := database.DB.First(&train, schedule.TrainID).Error; err != nil {
+				return errors.New("поезд не найден")
+			}
+			loaded = true
+		}
+		var track models.Track
+		if err := database.DB.First(&track, *trackID).Error; err != nil {
+			return errors.New("путь не найден")
+		}
+		if !TrackFits(&track, &train) {
+			return fmt.Errorf("состав из %d вагонов не помещается на путь %d (вместимость %d вагонов)",
+				train.WagonCount, track.Number, track.LengthWagons)
+		}
+	}
+
+	return nil
+}
+
+// fittingTracks возвращает пути станции, вмещающие состав, начиная с предпочтительного.
+func fittingTracks(stationID uint, preferredID *uint, train *models.Train) []models.Track {
+	var tracks []models.Track
+	database.DB.Where("station_id = ? AND (length_wagons = 0 OR length_wagons >= ?)", stationID, train.WagonCount).
+		Order("number ASC").Find(&tracks)
+
+	if preferredID != nil {
+		sort.SliceStable(tracks, func(i, j int) bool {
+			return tracks[i].ID == *preferredID && tracks[j].ID != *preferredID
+		})
+	}
+	return tracks
+}
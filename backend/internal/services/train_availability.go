@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// TrainTurnaround — минимальное время на оборот состава между двумя рейсами.
+var TrainTurnaround = 30 * time.Minute
+
+// TrainAvailabilityValidator проверяет занятость поездов; db и exclude имеют
+// тот же смысл, что и у ScheduleValidator.
+type TrainAvailabilityValidator struct {
+	db      *gorm.DB
+	exclude []uint
+}
+
+func (v *TrainAvailabilityValidator) conn() *gorm.DB {
+	if v.db != nil {
+		return v.db
+	}
+	return database.DB
+}
+
+// Excluding возвращает валидатор, не учитывающий рейсы ids.
+func (v *TrainAvailabilityValidator) Excluding(ids []uint) *TrainAvailabilityValidator {
+	return &TrainAvailabilityValidator{db: v.db, exclude: ids}
+}
+
+func (v *TrainAvailabilityValidator) ValidateTrainAvailability(schedule *models.Schedule) error {
+	if err := v.ValidateTrainOverlap(schedule); err != nil {
+		return err
+	}
+	if schedule.Status == models.StatusCancelled {
+		return nil
+	}
+
+	var previous, next models.Schedule
+
+	v.conn().Where(
+		"train_id = ? AND id NOT IN ? AND status != ? AND arrival_time <= ? AND deleted_at IS NULL",
+		schedule.TrainID,
+		excludedIDs(v.exclude, schedule.ID),
+		models.StatusCancelled,
+		schedule.DepartureTime,
+	).Order("arrival_time DESC").First(&previous)
+
+	if previous.ID != 0 && previous.ToStationID != nil && schedule.FromStationID != nil &&
+		*previous.ToStationID != *schedule.FromStationID {
+		return fmt.Errorf("нарушение непрерывности: предыдущий рейс #%d поезда прибывает на другую станцию", previous.ID)
+	}
+
+	v.conn().Where(
+		"train_id = ? AND id NOT IN ? AND status != ? AND departure_time >= ? AND deleted_at IS NULL",
+		schedule.TrainID,
+		excludedIDs(v.exclude, schedule.ID),
+		models.StatusCancelled,
+		schedule.ArrivalTime,
+	).Order("departure_time ASC").First(&next)
+
+	if next.ID != 0 && next.FromStationID != nil && schedule.ToStationID != nil &&
+		*next.FromStationID != *schedule.ToStationID {
+		return fmt.Errorf("нарушение непрерывности: следующий рейс #%d поезда отправляется с другой станции", next.ID)
+	}
+
+	return nil
+}
+
+// ValidateTrainOverlap проверяет только пересечение рейсов поезда по времени с
+// учётом оборота состава, без проверки непрерывности маршрута. Используется для
+// серий рейсов, где каждое повторение идёт по одному и тому же маршруту.
+func (v *TrainAvailabilityValidator) ValidateTrainOverlap(schedule *models.Schedule) error {
+	if schedule.Status == models.StatusCancelled {
+		return nil
+	}
+
+	var overlapping models.Schedule
+	err := v.conn().Where(
+		"train_id = ? AND id NOT IN ? AND status != ? AND deleted_at IS NULL AND departure_time < ? AND arrival_time > ?",
+		schedule.TrainID,
+		excludedIDs(v.exclude, schedule.ID),
+		models.StatusCancelled,
+		schedule.ArrivalTime.Add(TrainTurnaround),
+		schedule.DepartureTime.Add(-TrainTurnaround),
+	).Order("departure_time ASC").First(&overlapping).Error
+
+	if err == nil {
+		if overlapping.DepartureTime.Before(schedule.ArrivalTime) && overlapping.ArrivalTime.After(schedule.DepartureTime) {
+			return fmt.Errorf("поезд уже занят на рейсе #%d в указанное время", overlapping.ID)
+		}
+		return fmt.Errorf("недостаточно времени на оборот состава: требуется минимум %d минут между рейсами (рейс #%d)",
+			int(TrainTurnaround.Minutes()), overlapping.ID)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"railway-dispatcher/internal/database"
+	"railway-dispatcher/internal/models"
+	"railway-dispatcher/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// TwoFactorIssuer — название сервиса в приложении-аутентификаторе.
+const TwoFactorIssuer = "Railway Dispatcher"
+
+// recoveryCodeCount — число кодов восстановления, выдаваемых при подключении.
+const recoveryCodeCount = 10
+
+// TwoFactorRoles — роли, для которых двухфакторная аутентификация обязательна;
+// задаётся из конфигурации.
+var TwoFactorRoles = map[models.Role]bool{}
+
+var ErrInvalidTwoFactorCode = errors.New("неверный код подтверждения")
+
+// TwoFactorRequired сообщает, обязана ли роль использовать TOTP.
+func TwoFactorRequired(role models.Role) bool {
+	return TwoFactorRoles[role]
+}
+
+// StartTwoFactorSetup создаёт новый секрет TOTP пользователя. До подтверждения
+// кодом (EnableTwoFactor) секрет не используется при входе.
+func StartTwoFactorSetup(user *models.User) (secret, uri string, err error) {
+	if user.TOTPEnabled {
+		return "", "", errors.New("двухфакторная аутентификация уже подключена")
+	}
+	if secret, err = utils.GenerateTOTPSecret(); err != nil {
+		return "", "", err
+	}
+	if _, err = saveTwoFactor(user, map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_last_step": 0,
+	}); err != nil {
+		return "", "", err
+	}
+	user.TOTPSecret = secret
+	return secret, utils.TOTPURI(TwoFactorIssuer, user.Login, secret), nil
+}
+
+// EnableTwoFactor подтверждает подключение TOTP кодом из приложения и
+// возвращает коды восстановления. Коды показываются только один раз.
+func EnableTwoFactor(user *models.User, code string) ([]string, error) {
+	if user.TOTPEnabled {
+		return nil, errors.New("двухфакторная аутентификация уже подключена")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("сначала получите секрет для подключения")
+	}
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := saveTwoFactor(user, map[string]interface{}{
+		"totp_enabled":   true,
+		"totp_last_step": step,
+		"recovery_codes": hashes,
+	}); err != nil {
+		return nil, err
+	}
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	user.RecoveryCodes = hashes
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes заменяет все коды восстановления новыми.
+func RegenerateRecoveryCodes(user *models.User) ([]string, error) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := saveTwoFactor(user, map[string]interface{}{"recovery_codes": hashes}); err != nil {
+		return nil, err
+	}
+	user.RecoveryCodes = hashes
+	return codes, nil
+}
+
+// DisableTwoFactor отключает TOTP и удаляет секрет и коды восстановления.
+func DisableTwoFactor(user *models.User) error {
+	if _, err := saveTwoFactor(user, map[string]interface{}{
+		"totp_enabled":   false,
+		"totp_secret":    "",
+		"totp_last_step": 0,
+		"recovery_codes": "",
+	}); err != nil {
+		return err
+	}
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = ""
+	return nil
+}
+
+// VerifyTwoFactor проверяет код TOTP или, если он не подошёл, код
+// восстановления. Принятый код больше не принимается. usedRecovery сообщает,
+// что вход выполнен по коду восстановления.
+func VerifyTwoFactor(user *models.User, code string) (usedRecovery bool, err error) {
+	if !user.TOTPEnabled {
+		return false, errors.New("двухфакторная аутентификация не подключена")
+	}
+
+	if step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastStep); ok {
+		// Условие на шаг не даёт принять один код в двух параллельных запросах
+		saved, err := saveTwoFactor(user, map[string]interface{}{"totp_last_step": step}, "totp_last_step < ?", step)
+		if err != nil {
+			return false, err
+		}
+		if !saved {
+			return false, ErrInvalidTwoFactorCode
+		}
+		user.TOTPLastStep = step
+		return false, nil
+	}
+
+	hash := utils.HashToken(strings.ToLower(strings.TrimSpace(code)))
+	hashes := strings.Split(user.RecoveryCodes, ",")
+	for i, h := range hashes {
+		if h == "" || h != hash {
+			continue
+		}
+		remaining := strings.Join(append(hashes[:i:i], hashes[i+1:]...), ",")
+		saved, err := saveTwoFactor(user, map[string]interface{}{"recovery_codes": remaining}, "recovery_codes = ?", user.RecoveryCodes)
+		if err != nil {
+			return false, err
+		}
+		if !saved {
+			return false, ErrInvalidTwoFactorCode
+		}
+		user.RecoveryCodes = remaining
+		return true, nil
+	}
+	return false, ErrInvalidTwoFactorCode
+}
+
+// RecoveryCodesLeft возвращает число неиспользованных кодов восстановления.
+func RecoveryCodesLeft(user *models.User) int {
+	if user.RecoveryCodes == "" {
+		return 0
+	}
+	return len(strings.Split(user.RecoveryCodes, ","))
+}
+
+// saveTwoFactor сохраняет поля двухфакторной аутентификации при выполнении
+// условия conds и увеличивает версию записи, чтобы параллельное сохранение
+// пользователя целиком не вернуло старые значения. Возвращает false, если
+// условие не выполнилось.
+func saveTwoFactor(user *models.User, fields map[string]interface{}, conds ...interface{}) (bool, error) {
+	fields["version"] = gorm.Expr("version + 1")
+	query := database.DB.Model(user)
+	if len(conds) > 0 {
+		query = query.Where(conds[0], conds[1:]...)
+	}
+	result := query.Updates(fields)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	user.Version++
+	return true, nil
+}
+
+func newRecoveryCodes() (codes []string, hashes string, err error) {
+	if codes, err = utils.GenerateRecoveryCodes(recoveryCodeCount); err != nil {
+		return nil, "", err
+	}
+	list := make([]string, len(codes))
+	for i, code := range codes {
+		list[i] = utils.HashToken(code)
+	}
+	return codes, strings.Join(list, ","), nil
+}
@@ -1,6 +1,10 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -11,6 +15,12 @@ import (
 
 var jwtSecret []byte
 
+// Время жизни токенов доступа и обновления; переопределяются из конфигурации.
+var (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
 func InitJWT(secret string) {
 	jwtSecret = []byte(secret)
 }
@@ -19,16 +29,49 @@ type Claims struct {
 	UserID uint        `json:"user_id"`
 	Login  string      `json:"login"`
 	Role   models.Role `json:"role"`
+	// TokenVersion сверяется с User.TokenVersion: токены старой версии отозваны
+	TokenVersion uint `json:"tv"`
+	// Purpose задан у промежуточных токенов входа, которые принимаются только
+	// на шаге двухфакторной аутентификации
+	Purpose string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
 func GenerateToken(user *models.User) (string, error) {
 	claims := &Claims{
-		UserID: user.ID,
-		Login:  user.Login,
-		Role:   user.Role,
+		UserID:       user.ID,
+		Login:        user.Login,
+		Role:         user.Role,
+		TokenVersion: user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecret)
+}
+
+// Назначения промежуточных токенов входа.
+const (
+	PurposeTwoFactor      = "2fa"       // Ввод кода TOTP
+	PurposeTwoFactorSetup = "2fa_setup" // Обязательное подключение TOTP
+)
+
+// PreAuthTokenTTL — время на ввод кода двухфакторной аутентификации.
+const PreAuthTokenTTL = 5 * time.Minute
+
+// GeneratePreAuthToken выдаёт токен, подтверждающий только проверку пароля.
+func GeneratePreAuthToken(user *models.User, purpose string) (string, error) {
+	claims := &Claims{
+		UserID:       user.ID,
+		Login:        user.Login,
+		Role:         user.Role,
+		TokenVersion: user.TokenVersion,
+		Purpose:      purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(PreAuthTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -52,3 +95,20 @@ func ValidateToken(tokenString string) (*Claims, error) {
 
 	return claims, nil
 }
+
+// GenerateRefreshToken возвращает случайный токен обновления и его хеш для
+// хранения в базе.
+func GenerateRefreshToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken возвращает SHA-256 токена в hex.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
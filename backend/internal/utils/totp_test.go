@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// Секрет тестовых векторов RFC 6238 для SHA-1.
+var rfc6238Key = []byte("12345678901234567890")
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// Коды RFC 6238 восьмизначные, приложения используют последние шесть цифр
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		if got := totpCode(rfc6238Key, tt.unix/totpPeriod); got != tt.want {
+			t.Errorf("код для %d: %s, ожидался %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret := totpEncoding.EncodeToString(rfc6238Key)
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+	code := func(step int64) string { return totpCode(rfc6238Key, step) }
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"текущий шаг", code(current), 0, current, true},
+		{"код с пробелом", code(current)[:3] + " " + code(current)[3:], 0, current, true},
+		{"предыдущий шаг в пределах допуска", code(current - 1), 0, current - 1, true},
+		{"следующий шаг в пределах допуска", code(current + 1), 0, current + 1, true},
+		{"шаг за пределами допуска в прошлом", code(current - 2), 0, 0, false},
+		{"шаг за пределами допуска в будущем", code(current + 2), 0, 0, false},
+		{"повтор уже использованного кода", code(current), current, 0, false},
+		{"код шага раньше использованного", code(current - 1), current, 0, false},
+		{"следующий код после использованного", code(current + 1), current, current + 1, true},
+		{"неверная длина", "12345", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(secret, tt.code, now, tt.lastStep)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("ValidateTOTP = (%d, %v), ожидалось (%d, %v)", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
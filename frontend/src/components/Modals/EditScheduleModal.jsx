@@ -87,12 +87,15 @@ export default function EditScheduleModal({ isOpen, onClose, schedule, trains, o
                 arrival_time: arr.toISOString(),
                 from_station_id: formData.from_station_id ? parseInt(formData.from_station_id) : null,
                 to_station_id: formData.to_station_id ? parseInt(formData.to_station_id) : null,
-                status: formData.status
-            })
+                // Остальные статусы сервер рассчитывает по времени рейса
+                status: formData.status === 'Cancelled' ? 'Cancelled' : undefined
+            }, undefined, schedule.version)
             onSuccess()
             onClose()
         } catch (err) {
-            setError(err.response?.data?.error || 'Ошибка сохранения')
+            setError(err.response?.status === 409 && err.response.data?.current
+                ? 'Рейс изменён другим пользователем, закройте окно и откройте его снова'
+                : err.response?.data?.error || 'Ошибка сохранения')
         } finally { setLoading(false) }
     }
 
@@ -152,7 +155,7 @@ export default function EditScheduleModal({ isOpen, onClose, schedule, trains, o
                         <div>
                             <label className="block text-sm font-medium text-slate-700 mb-1">Статус</label>
                             <select className="input-field" value={formData.status} onChange={e => handleChange('status', e.target.value)}>
-                                {Object.entries(statusNames).map(([key, val]) => <option key={key} value={key}>{val}</option>)}
+                                {Object.entries(statusNames).filter(([key]) => key === schedule.status || key === 'Cancelled').map(([key, val]) => <option key={key} value={key}>{val}</option>)}
                             </select>
                         </div>
                     </div>
@@ -29,6 +29,7 @@ export default function StationsModal({ isOpen, onClose }) {
     const [loading, setLoading] = useState(true)
     const [showAdd, setShowAdd] = useState(false)
     const [editingId, setEditingId] = useState(null)
+    const [editingVersion, setEditingVersion] = useState(null)
     const [form, setForm] = useState({ name: '', code: '' })
     const [error, setError] = useState('')
     const [success, setSuccess] = useState('')
@@ -52,11 +53,11 @@ export default function StationsModal({ isOpen, onClose }) {
         }
     }
 
-    const handleEdit = (station) => { setEditingId(station.id); setForm({ name: station.name, code: station.code || '' }); setError('') }
+    const handleEdit = (station) => { setEditingId(station.id); setEditingVersion(station.version); setForm({ name: station.name, code: station.code || '' }); setError('') }
 
     const handleSave = async () => {
         if (!form.name || !form.code) { setError('Название и код обязательны'); return }
-        try { await updateStation(editingId, form); fetchStations(); setEditingId(null); setForm({ name: '', code: '' }); setSuccess('Обновлено'); setTimeout(() => setSuccess(''), 3000) } catch (err) { setError('Ошибка сохранения') }
+        try { await updateStation(editingId, form, editingVersion); fetchStations(); setEditingId(null); setForm({ name: '', code: '' }); setSuccess('Обновлено'); setTimeout(() => setSuccess(''), 3000) } catch (err) { setError(err.response?.status === 409 ? 'Станция изменена другим пользователем, обновите список' : 'Ошибка сохранения') }
     }
 
     const handleDeleteClick = (station) => setDeleteConfirm({ show: true, id: station.id, name: station.name })
@@ -4,6 +4,7 @@ import { updateTrain, deleteTrain, createTrain } from '../../services/api'
 
 export default function TrainsListModal({ isOpen, onClose, trains, onTrainUpdated }) {
     const [editingTrain, setEditingTrain] = useState(null)
+    const [editingVersion, setEditingVersion] = useState(null)
     const [showAdd, setShowAdd] = useState(false)
     const [editForm, setEditForm] = useState({})
     const [addForm, setAddForm] = useState({ number: '', type: 'freight', wagon_count: 0, max_speed: 0, description: '' })
@@ -20,6 +21,7 @@ export default function TrainsListModal({ isOpen, onClose, trains, onTrainUpdate
 
     const handleEdit = (train) => {
         setEditingTrain(train.id)
+        setEditingVersion(train.version)
         setEditForm({
             number: train.number,
             type: train.type,
@@ -38,13 +40,13 @@ export default function TrainsListModal({ isOpen, onClose, trains, onTrainUpdate
                 ...editForm,
                 wagon_count: parseInt(editForm.wagon_count),
                 max_speed: parseInt(editForm.max_speed)
-            })
+            }, editingVersion)
             setEditingTrain(null)
             onTrainUpdated()
             setSuccess('Поезд обновлён')
             setTimeout(() => setSuccess(''), 3000)
         } catch (err) {
-            setError('Ошибка сохранения')
+            setError(err.response?.status === 409 ? 'Поезд изменён другим пользователем, обновите список' : 'Ошибка сохранения')
         } finally {
             setLoading(false)
         }
@@ -1,5 +1,5 @@
 import { createContext, useContext, useState, useEffect, useCallback } from 'react'
-import { getMe } from '../services/api'
+import { getMe, logout as apiLogout } from '../services/api'
 
 const AuthContext = createContext(null)
 
@@ -31,7 +31,9 @@ export function AuthProvider({ children }) {
     }
 
     const logout = useCallback((callback) => {
+        apiLogout().catch(() => {})
         localStorage.removeItem('token')
+        localStorage.removeItem('refresh_token')
         setUser(null)
         if (callback) callback()
     }, [])
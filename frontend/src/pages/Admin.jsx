@@ -24,12 +24,13 @@ export default function Admin() {
         }
     }
 
-    const handleRoleChange = async (userId, newRole) => {
+    const handleRoleChange = async (user, newRole) => {
         try {
-            await updateUser(userId, { role: newRole })
+            await updateUser(user.id, { role: newRole }, user.version)
             fetchData()
         } catch (err) {
-            alert('Ошибка обновления роли')
+            alert(err.response?.status === 409 ? 'Пользователь изменён другим администратором, список обновлён' : 'Ошибка обновления роли')
+            if (err.response?.status === 409) fetchData()
         }
     }
 
@@ -99,7 +100,7 @@ export default function Admin() {
                                         <td className="py-4 px-4">
                                             <select
                                                 value={user.role}
-                                                onChange={(e) => handleRoleChange(user.id, e.target.value)}
+                                                onChange={(e) => handleRoleChange(user, e.target.value)}
                                                 className="bg-slate-100 border-none rounded-lg text-xs py-1 px-3 font-medium text-slate-600 focus:ring-2 focus:ring-primary-500/20"
                                             >
                                                 <option value="Admin">Администратор</option>
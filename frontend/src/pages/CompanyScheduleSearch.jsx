@@ -1,13 +1,15 @@
-import { useState, useEffect, useMemo } from 'react'
-import { getSchedules, getStations } from '../services/api'
+import { useState, useEffect } from 'react'
+import { getStations, searchJourneys } from '../services/api'
 import DatePicker from '../components/Calendar/DatePicker'
 
+// Сервер ищет поездки не более чем на 14 дней за запрос
+const MAX_SEARCH_DAYS = 14
+
 export default function CompanyScheduleSearch() {
-    const [allSchedules, setAllSchedules] = useState([])
     const [stations, setStations] = useState([])
     const [legs, setLegs] = useState([{ from: '', to: '', date: '', transfer_time: 0 }])
     const [searchResults, setSearchResults] = useState([])
-    const [maxTransfers, setMaxTransfers] = useState(2)
+    const [maxLegs, setMaxLegs] = useState(2)
     const [nearbyDays, setNearbyDays] = useState(3)
     const [allowMultiDay, setAllowMultiDay] = useState(true)
 
@@ -15,17 +17,11 @@ export default function CompanyScheduleSearch() {
 
     const fetchData = async () => {
         try {
-            const [schedRes, stationsRes] = await Promise.all([getSchedules(), getStations()])
-            setAllSchedules(schedRes.data || [])
+            const stationsRes = await getStations()
             setStations(stationsRes.data || [])
         } catch (err) { console.error(err) }
     }
 
-    const schedules = useMemo(() => {
-        const now = new Date()
-        return allSchedules.filter(s => new Date(s.arrival_time) >= now)
-    }, [allSchedules])
-
     const addLeg = () => {
         const lastLeg = legs[legs.length - 1]
         setLegs([...legs, { from: lastLeg.to, to: '', date: '', transfer_time: 30 }])
@@ -55,102 +51,75 @@ export default function CompanyScheduleSearch() {
         return 'Scheduled'
     }
 
-    const findDirectSchedules = (fromId, toId, date) => {
-        return schedules.filter(s => {
-            const matchFrom = !fromId || parseInt(s.from_station_id) === parseInt(fromId)
-            const matchTo = !toId || parseInt(s.to_station_id) === parseInt(toId)
-            const matchDate = !date || new Date(s.departure_time).toDateString() === new Date(date).toDateString()
-            return matchFrom && matchTo && matchDate
-        })
+    const shiftDate = (date, days) => {
+        const d = new Date(`${date}T00:00:00`)
+        d.setDate(d.getDate() + days)
+        return `${d.getFullYear()}-${String(d.getMonth() + 1).padStart(2, '0')}-${String(d.getDate()).padStart(2, '0')}`
     }
 
-    const findNearbySchedules = (fromId, toId, date, daysRange) => {
-        if (!date) return []
-        const targetDate = new Date(date)
-        const now = new Date()
-        return schedules.filter(s => {
-            const matchFrom = !fromId || parseInt(s.from_station_id) === parseInt(fromId)
-            const matchTo = !toId || parseInt(s.to_station_id) === parseInt(toId)
-            const schedDate = new Date(s.departure_time)
-            if (schedDate < now) return false // Skip past
-            const diffDays = Math.abs((schedDate - targetDate) / (1000 * 60 * 60 * 24))
-            return matchFrom && matchTo && diffDays <= daysRange && diffDays > 0
-        }).sort((a, b) => Math.abs(new Date(a.departure_time) - targetDate) - Math.abs(new Date(b.departure_time) - targetDate))
-    }
+    // Участок поездки в виде, который понимает карточка рейса
+    const toSegment = (leg) => ({
+        ...leg,
+        id: leg.schedule_id,
+        train: { number: leg.train_number },
+        from_station: { name: leg.from_station_name },
+        to_station: { name: leg.to_station_name },
+    })
 
-    const findTransferRoutes = (fromId, toId, startDate, maxHops) => {
+    const findJourneys = async (fromId, toId, params) => {
         if (!fromId || !toId) return []
-        const fromIdInt = parseInt(fromId)
-        const toIdInt = parseInt(toId)
-        const routes = []
-        const startDateObj = startDate ? new Date(startDate) : null
-        const now = new Date()
-
-        const queue = [{ path: [], currentStation: fromIdInt, lastArrival: startDateObj, visited: new Set([fromIdInt]) }]
-
-        while (queue.length > 0 && routes.length < 10) {
-            const { path, currentStation, lastArrival, visited } = queue.shift()
-            if (path.length >= maxHops) continue
-
-            const departures = schedules.filter(s => {
-                const sFromId = parseInt(s.from_station_id)
-                const sToId = parseInt(s.to_station_id)
-                if (sFromId !== currentStation) return false
-                if (visited.has(sToId)) return false
-
-                const depTime = new Date(s.departure_time)
-                if (depTime < now) return false // Skip past schedules
-
-                if (path.length === 0 && startDateObj) {
-                    if (allowMultiDay) {
-                        const diffDays = (depTime - startDateObj) / (1000 * 60 * 60 * 24)
-                        if (diffDays < 0 || diffDays > nearbyDays) return false
-                    } else {
-                        if (depTime.toDateString() !== startDateObj.toDateString()) return false
-                    }
-                }
-
-                if (path.length > 0 && lastArrival) {
-                    if (depTime < lastArrival) return false
-                    const waitDays = (depTime - lastArrival) / (1000 * 60 * 60 * 24)
-                    if (waitDays > nearbyDays) return false
-                }
-
-                return true
-            })
+        try {
+            const res = await searchJourneys({ from: fromId, to: toId, limit: 20, ...params })
+            return (res.data || []).map(journey => journey.legs.map(toSegment))
+        } catch (err) {
+            console.error(err)
+            return []
+        }
+    }
 
-            for (const sched of departures) {
-                const sToId = parseInt(sched.to_station_id)
-                const newPath = [...path, sched]
-                const newArrival = new Date(sched.arrival_time)
+    // Без даты ищем на всю доступную глубину начиная с сегодняшнего дня
+    const findDirectSchedules = async (fromId, toId, date) => {
+        const routes = await findJourneys(fromId, toId, { date: date || undefined, days: date ? 1 : MAX_SEARCH_DAYS, max_transfers: 0 })
+        return routes.map(route => route[0])
+    }
 
-                if (sToId === toIdInt) {
-                    routes.push(newPath)
-                } else if (newPath.length < maxHops) {
-                    const newVisited = new Set(visited)
-                    newVisited.add(sToId)
-                    queue.push({ path: newPath, currentStation: sToId, lastArrival: newArrival, visited: newVisited })
-                }
-            }
-        }
-        return routes
+    const findNearbySchedules = async (fromId, toId, date, daysRange) => {
+        if (!date) return []
+        const targetDate = new Date(`${date}T00:00:00`)
+        const now = new Date()
+        const days = Math.min(daysRange, MAX_SEARCH_DAYS)
+        const [before, after] = await Promise.all([
+            findJourneys(fromId, toId, { date: shiftDate(date, -days), days, max_transfers: 0 }),
+            findJourneys(fromId, toId, { date: shiftDate(date, 1), days, max_transfers: 0 }),
+        ])
+        return [...before, ...after]
+            .map(route => route[0])
+            .filter(s => new Date(s.departure_time) >= now)
+            .sort((a, b) => Math.abs(new Date(a.departure_time) - targetDate) - Math.abs(new Date(b.departure_time) - targetDate))
     }
 
-    const handleSearch = () => {
-        const results = legs.map((leg, i) => {
+    // В поездке из maxLegs участков на одну пересадку меньше
+    const findTransferRoutes = (fromId, toId, startDate) => findJourneys(fromId, toId, {
+        date: startDate || undefined,
+        days: !startDate ? MAX_SEARCH_DAYS : allowMultiDay ? Math.min(nearbyDays, MAX_SEARCH_DAYS) : 1,
+        max_transfers: maxLegs - 1,
+    })
+
+    const handleSearch = async () => {
+        const results = await Promise.all(legs.map(async (leg, i) => {
             const fromStation = stations.find(s => s.id == leg.from)
             const toStation = stations.find(s => s.id == leg.to)
-            let directSchedules = findDirectSchedules(leg.from, leg.to, leg.date)
+            const directSchedules = await findDirectSchedules(leg.from, leg.to, leg.date)
             let transferRoutes = []
 
-            if (directSchedules.length === 0 && leg.from && leg.to) {
-                transferRoutes = findTransferRoutes(leg.from, leg.to, leg.date, maxTransfers)
+            if (directSchedules.length === 0 && leg.from && leg.to && maxLegs > 1) {
+                transferRoutes = await findTransferRoutes(leg.from, leg.to, leg.date)
                 transferRoutes = transferRoutes.filter(route => route.length > 1)
             }
 
             let nearbySchedules = []
             if (directSchedules.length === 0 && transferRoutes.length === 0 && leg.date) {
-                nearbySchedules = findNearbySchedules(leg.from, leg.to, leg.date, nearbyDays)
+                nearbySchedules = await findNearbySchedules(leg.from, leg.to, leg.date, nearbyDays)
             }
 
             return {
@@ -163,7 +132,7 @@ export default function CompanyScheduleSearch() {
                 nearbySchedules,
                 hasResults: directSchedules.length > 0 || transferRoutes.length > 0
             }
-        })
+        }))
         setSearchResults(results)
     }
 
@@ -190,7 +159,7 @@ export default function CompanyScheduleSearch() {
                     <span className={`px-2.5 py-0.5 rounded-full text-xs font-bold ${statusColors[computedStatus]}`}>
                         {statusNames[computedStatus]}
                     </span>
-                    <span className="text-xs text-slate-400">Путь {schedule.track_number}</span>
+                    {schedule.track_number > 0 && <span className="text-xs text-slate-400">Путь {schedule.track_number}</span>}
                 </div>
                 <div className="font-bold text-slate-800">{schedule.train?.number || `Поезд #${schedule.train_id}`}</div>
                 <div className="bg-slate-50 rounded-xl p-3 flex items-center gap-3">
@@ -231,8 +200,8 @@ export default function CompanyScheduleSearch() {
                 <h2 className="text-xl font-bold text-slate-800 mb-6">Поиск маршрутов</h2>
                 <div className="bg-slate-50 rounded-xl p-4 mb-4 space-y-3">
                     <div className="flex items-center justify-between">
-                        <span className="text-sm font-medium text-slate-700">Макс. пересадок</span>
-                        <select className="input-field w-20 text-sm py-1" value={maxTransfers} onChange={e => setMaxTransfers(parseInt(e.target.value))}>
+                        <span className="text-sm font-medium text-slate-700">Макс. участков в маршруте</span>
+                        <select className="input-field w-20 text-sm py-1" value={maxLegs} onChange={e => setMaxLegs(parseInt(e.target.value))}>
                             {[1, 2, 3, 4].map(n => <option key={n} value={n}>{n}</option>)}
                         </select>
                     </div>
@@ -1,7 +1,7 @@
 import { useState, useEffect } from 'react'
 import { useNavigate, useParams, Link } from 'react-router-dom'
 import { useAuth } from '../context/AuthContext'
-import { login as apiLogin, getMe } from '../services/api'
+import { login as apiLogin, getMe, loginTwoFactor, setupTwoFactorLogin, enableTwoFactorLogin } from '../services/api'
 
 export default function Login() {
     const { role } = useParams()
@@ -9,6 +9,10 @@ export default function Login() {
     const [password, setPassword] = useState('')
     const [error, setError] = useState('')
     const [loading, setLoading] = useState(false)
+    const [twoFactor, setTwoFactor] = useState(null)
+    const [code, setCode] = useState('')
+    const [recoveryCodes, setRecoveryCodes] = useState(null)
+    const [pendingLogin, setPendingLogin] = useState(null)
     const { login: authLogin, user } = useAuth()
     const navigate = useNavigate()
 
@@ -31,31 +35,63 @@ export default function Login() {
         else navigate('/shipping')
     }
 
+    const finishLogin = async (data) => {
+        const token = data.token
+        localStorage.setItem('token', token)
+        localStorage.setItem('refresh_token', data.refresh_token)
+        const userRes = await getMe()
+        const userData = userRes.data
+
+        const allowedRoles = getAllowedRoles()
+        if (!allowedRoles.includes(userData.role)) {
+            localStorage.removeItem('token')
+            localStorage.removeItem('refresh_token')
+            setError('Пользователь не найден')
+            return
+        }
+
+        authLogin(token, userData)
+        redirectBasedOnRole(userData.role)
+    }
+
     const handleSubmit = async (e) => {
         e.preventDefault()
         setLoading(true)
         setError('')
         try {
-            const res = await apiLogin(loginValue, password)
-            const token = res.data.token
-            localStorage.setItem('token', token)
-            const userRes = await getMe()
-            const userData = userRes.data
-
-            const allowedRoles = getAllowedRoles()
-            if (!allowedRoles.includes(userData.role)) {
-                localStorage.removeItem('token')
-                setError('Пользователь не найден')
+            if (twoFactor) {
+                if (twoFactor.purpose === '2fa_setup') {
+                    const res = await enableTwoFactorLogin(twoFactor.token, code)
+                    // Коды восстановления показываются один раз, вход завершается после их сохранения
+                    setRecoveryCodes(res.data.recovery_codes)
+                    setPendingLogin(res.data)
+                } else {
+                    const res = await loginTwoFactor(twoFactor.token, code)
+                    await finishLogin(res.data)
+                }
                 return
             }
 
-            authLogin(token, userData)
-            redirectBasedOnRole(userData.role)
+            const res = await apiLogin(loginValue, password)
+            if (res.data.pre_auth_token) {
+                const state = { purpose: res.data.two_factor, token: res.data.pre_auth_token }
+                if (state.purpose === '2fa_setup') {
+                    const setup = await setupTwoFactorLogin(state.token)
+                    state.secret = setup.data.secret
+                    state.uri = setup.data.uri
+                }
+                setTwoFactor(state)
+                return
+            }
+            await finishLogin(res.data)
         } catch (err) {
             console.error(err)
             localStorage.removeItem('token')
             if (err.message === 'Network Error') setError('Сервер недоступен.')
+            else if (twoFactor && (err.response?.status === 401 || err.response?.status === 400)) setError('Неверный код подтверждения')
             else if (err.response?.status === 401) setError('Неверный логин или пароль')
+            else if (err.response?.status === 403) setError(err.response.data?.error || 'Вход запрещён')
+            else if (err.response?.status === 429) setError(err.response.data?.error || 'Слишком много попыток входа')
             else setError('Ошибка входа.')
         } finally {
             setLoading(false)
@@ -99,17 +135,48 @@ export default function Login() {
                             {error}
                         </div>
                     )}
-                    <div>
-                        <label className="block text-sm font-medium text-slate-700 mb-2 ml-1">Логин</label>
-                        <input type="text" value={loginValue} onChange={(e) => setLoginValue(e.target.value)} className="input-field" placeholder="Введите логин" required />
-                    </div>
-                    <div>
-                        <label className="block text-sm font-medium text-slate-700 mb-2 ml-1">Пароль</label>
-                        <input type="password" value={password} onChange={(e) => setPassword(e.target.value)} className="input-field" placeholder="••••••••" required />
-                    </div>
-                    <button type="submit" disabled={loading} className={`w-full py-3.5 text-lg font-medium text-white rounded-full hover:opacity-90 transition-all shadow-lg ${currentStyle.bg}`}>
-                        {loading ? 'Загрузка...' : 'Войти'}
-                    </button>
+                    {recoveryCodes ? (
+                        <div>
+                            <p className="text-sm text-slate-600 mb-3">Сохраните коды восстановления. Каждый код можно использовать один раз, если устройство с приложением будет недоступно.</p>
+                            <div className="grid grid-cols-2 gap-2 font-mono text-sm bg-slate-50 rounded-xl p-4">
+                                {recoveryCodes.map((c) => <span key={c}>{c}</span>)}
+                            </div>
+                        </div>
+                    ) : twoFactor ? (
+                        <>
+                            {twoFactor.purpose === '2fa_setup' && (
+                                <div className="text-sm text-slate-600 space-y-2">
+                                    <p>Для вашей роли обязательна двухфакторная аутентификация. Добавьте ключ в приложение-аутентификатор:</p>
+                                    <p className="font-mono break-all bg-slate-50 rounded-xl p-3">{twoFactor.secret}</p>
+                                    <a href={twoFactor.uri} className={`font-semibold ${currentStyle.text} hover:underline`}>Открыть в приложении</a>
+                                </div>
+                            )}
+                            <div>
+                                <label className="block text-sm font-medium text-slate-700 mb-2 ml-1">Код подтверждения</label>
+                                <input type="text" value={code} onChange={(e) => setCode(e.target.value)} className="input-field" placeholder={twoFactor.purpose === '2fa' ? 'Код из приложения или код восстановления' : '123456'} autoComplete="one-time-code" required />
+                            </div>
+                        </>
+                    ) : (
+                        <>
+                            <div>
+                                <label className="block text-sm font-medium text-slate-700 mb-2 ml-1">Логин</label>
+                                <input type="text" value={loginValue} onChange={(e) => setLoginValue(e.target.value)} className="input-field" placeholder="Введите логин" required />
+                            </div>
+                            <div>
+                                <label className="block text-sm font-medium text-slate-700 mb-2 ml-1">Пароль</label>
+                                <input type="password" value={password} onChange={(e) => setPassword(e.target.value)} className="input-field" placeholder="••••••••" required />
+                            </div>
+                        </>
+                    )}
+                    {recoveryCodes ? (
+                        <button type="button" onClick={() => finishLogin(pendingLogin)} className={`w-full py-3.5 text-lg font-medium text-white rounded-full hover:opacity-90 transition-all shadow-lg ${currentStyle.bg}`}>
+                            Я сохранил коды
+                        </button>
+                    ) : (
+                        <button type="submit" disabled={loading} className={`w-full py-3.5 text-lg font-medium text-white rounded-full hover:opacity-90 transition-all shadow-lg ${currentStyle.bg}`}>
+                            {loading ? 'Загрузка...' : twoFactor ? 'Подтвердить' : 'Войти'}
+                        </button>
+                    )}
                 </form>
                 {config.link && (
                     <div className="text-center mt-8 pt-6 border-t border-slate-100">
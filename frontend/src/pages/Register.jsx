@@ -11,6 +11,7 @@ export default function Register() {
     const [error, setError] = useState('')
     const [loading, setLoading] = useState(false)
     const [showSuccess, setShowSuccess] = useState(false)
+    const [pending, setPending] = useState(false)
     const navigate = useNavigate()
 
     const config = role === 'carrier'
@@ -40,7 +41,8 @@ export default function Register() {
         const backendRole = role === 'carrier' ? 'Carrier' : 'Company'
 
         try {
-            await register(login, password, backendRole)
+            const res = await register(login, password, backendRole)
+            setPending(res.status === 202)
             setShowSuccess(true)
         } catch (err) {
             console.error(err)
@@ -107,14 +109,18 @@ export default function Register() {
                 </div>
             </div>
 
-            <Modal isOpen={showSuccess} onClose={() => navigate(`/login/${role}`)} title="Регистрация успешна!">
+            <Modal isOpen={showSuccess} onClose={() => navigate(`/login/${role}`)} title={pending ? 'Заявка отправлена' : 'Регистрация успешна!'}>
                 <div className="text-center">
                     <div className="w-16 h-16 bg-green-100 rounded-full flex items-center justify-center mx-auto mb-4">
                         <svg className="w-8 h-8 text-green-600" fill="none" stroke="currentColor" viewBox="0 0 24 24">
                             <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M5 13l4 4L19 7" />
                         </svg>
                     </div>
-                    <p className="text-slate-600 mb-6">Аккаунт успешно создан! Теперь вы можете войти в систему.</p>
+                    <p className="text-slate-600 mb-6">
+                        {pending
+                            ? 'Аккаунт перевозчика станет доступен после подтверждения администратором.'
+                            : 'Аккаунт успешно создан! Теперь вы можете войти в систему.'}
+                    </p>
                     <button
                         onClick={() => navigate(`/login/${role}`)}
                         className={`w-full py-3 text-white font-medium rounded-full ${bgTheme}`}
@@ -9,15 +9,45 @@ const api = axios.create({
 
 api.interceptors.request.use((config) => {
     const token = localStorage.getItem('token')
-    if (token) {
+    if (token && !config.headers.Authorization) {
         config.headers.Authorization = `Bearer ${token}`
     }
     return config
 })
 
+// Истёкший токен доступа обновляется по refresh_token один раз на запрос;
+// параллельные запросы ждут одного и того же обновления.
+let refreshing = null
+
+const refreshTokens = () => {
+    const refreshToken = localStorage.getItem('refresh_token')
+    if (!refreshToken) return Promise.reject(new Error('no refresh token'))
+    if (!refreshing) {
+        refreshing = axios.post('/api/refresh', { refresh_token: refreshToken })
+            .then((res) => {
+                localStorage.setItem('token', res.data.token)
+                localStorage.setItem('refresh_token', res.data.refresh_token)
+                return res.data.token
+            })
+            .finally(() => { refreshing = null })
+    }
+    return refreshing
+}
+
 api.interceptors.response.use(
     (response) => response,
-    (error) => {
+    async (error) => {
+        const original = error.config
+        if (error.response?.status === 401 && original && !original._retry && !original.url?.startsWith('/login')) {
+            original._retry = true
+            try {
+                const token = await refreshTokens()
+                original.headers.Authorization = `Bearer ${token}`
+                return api(original)
+            } catch (refreshError) {
+                localStorage.removeItem('refresh_token')
+            }
+        }
         if (error.response?.status === 401) {
             localStorage.removeItem('token')
         }
@@ -25,28 +55,60 @@ api.interceptors.response.use(
     }
 )
 
+// Версия записи, полученная при чтении, передаётся в If-Match: сервер отклонит
+// сохранение с 409, если запись успели изменить.
+const ifMatch = (version) => (version ? { 'If-Match': `"${version}"` } : {})
+
 export const login = (login, password) => api.post('/login', { login, password })
+const preAuth = (token) => ({ headers: { Authorization: `Bearer ${token}` } })
+export const loginTwoFactor = (preAuthToken, code) => api.post('/login/2fa', { code }, preAuth(preAuthToken))
+export const setupTwoFactorLogin = (preAuthToken) => api.post('/login/2fa/setup', null, preAuth(preAuthToken))
+export const enableTwoFactorLogin = (preAuthToken, code) => api.post('/login/2fa/enable', { code }, preAuth(preAuthToken))
+export const setupTwoFactor = () => api.post('/2fa/setup')
+export const enableTwoFactor = (code) => api.post('/2fa/enable', { code })
+export const disableTwoFactor = (password, code) => api.post('/2fa/disable', { password, code })
+export const regenerateRecoveryCodes = (code) => api.post('/2fa/recovery-codes', { code })
+export const logout = (all = false) => api.post('/logout', { refresh_token: localStorage.getItem('refresh_token') || '' }, {
+    params: { all: all || undefined },
+    headers: { Authorization: `Bearer ${localStorage.getItem('token')}` },
+})
 export const register = (login, password, role) => api.post('/register', { login, password, role })
 export const getMe = () => api.get('/me')
 
 export const getStats = () => api.get('/stats')
 
-export const getTrains = () => api.get('/trains')
+export const getTrains = (params) => api.get('/trains', { params })
 export const getTrain = (id) => api.get(`/trains/${id}`)
 export const createTrain = (data) => api.post('/trains', data)
-export const updateTrain = (id, data) => api.put(`/trains/${id}`, data)
+export const updateTrain = (id, data, version) => api.put(`/trains/${id}`, data, { headers: ifMatch(version) })
+export const patchTrain = (id, data) => api.patch(`/trains/${id}`, data, { headers: { 'Content-Type': 'application/merge-patch+json' } })
 export const deleteTrain = (id) => api.delete(`/trains/${id}`)
 
-export const getSchedules = () => api.get('/schedules')
+export const getSchedules = (params) => api.get('/schedules', { params })
+export const findScheduleSlots = (params) => api.get('/schedules/slots', { params })
+export const exportSchedules = (params) => api.get('/schedules/export', { params, responseType: 'blob' })
 export const getSchedule = (id) => api.get(`/schedules/${id}`)
 export const createSchedule = (data) => api.post('/schedules', data)
-export const updateSchedule = (id, data) => api.put(`/schedules/${id}`, data)
-export const deleteSchedule = (id) => api.delete(`/schedules/${id}`)
+export const previewSchedule = (data) => api.post('/schedules', data, { params: { dry_run: true } })
+export const updateSchedule = (id, data, scope, version) => api.put(`/schedules/${id}`, data, { params: { scope }, headers: ifMatch(version) })
+export const patchSchedule = (id, data) => api.patch(`/schedules/${id}`, data, { headers: { 'Content-Type': 'application/merge-patch+json' } })
+export const deleteSchedule = (id, scope) => api.delete(`/schedules/${id}`, { params: { scope } })
+export const cancelSchedule = (id, scope) => api.post(`/schedules/${id}/cancel`, null, { params: { scope } })
+export const importSchedules = (file, { format, dryRun } = {}) => {
+  const data = new FormData()
+  data.append('file', file)
+  return api.post('/schedules/import', data, { params: { format, dry_run: dryRun || undefined } })
+}
+export const getScheduleSeries = (id) => api.get(`/schedules/${id}/series`)
+export const reportDelay = (id, data) => api.post(`/schedules/${id}/delay`, data)
+
+export const searchJourneys = (params) => api.get('/journeys', { params })
 
-export const getStations = () => api.get('/stations')
+export const getStations = (params) => api.get('/stations', { params })
 export const getStation = (id) => api.get(`/stations/${id}`)
 export const createStation = (data) => api.post('/stations', data)
-export const updateStation = (id, data) => api.put(`/stations/${id}`, data)
+export const updateStation = (id, data, version) => api.put(`/stations/${id}`, data, { headers: ifMatch(version) })
+export const patchStation = (id, data) => api.patch(`/stations/${id}`, data, { headers: { 'Content-Type': 'application/merge-patch+json' } })
 export const deleteStation = (id) => api.delete(`/stations/${id}`)
 
 export const getStationDistances = () => api.get('/station-distances')
@@ -54,11 +116,26 @@ export const createStationDistance = (data) => api.post('/station-distances', da
 export const updateStationDistance = (id, data) => api.put(`/station-distances/${id}`, data)
 export const deleteStationDistance = (id) => api.delete(`/station-distances/${id}`)
 
-export const getUsers = () => api.get('/users')
+export const getMaintenanceRules = (params) => api.get('/maintenance-rules', { params })
+export const createMaintenanceRule = (data) => api.post('/maintenance-rules', data)
+export const updateMaintenanceRule = (id, data) => api.put(`/maintenance-rules/${id}`, data)
+export const deleteMaintenanceRule = (id) => api.delete(`/maintenance-rules/${id}`)
+
+export const getTrackClosures = (params) => api.get('/track-closures', { params })
+export const createTrackClosure = (data) => api.post('/track-closures', data)
+export const updateTrackClosure = (id, data) => api.put(`/track-closures/${id}`, data)
+export const deleteTrackClosure = (id) => api.delete(`/track-closures/${id}`)
+export const getClosureImpact = (id) => api.get(`/track-closures/${id}/impacted`)
+
+export const getUsers = (params) => api.get('/users', { params })
 export const getUser = (id) => api.get(`/users/${id}`)
-export const updateUser = (id, data) => api.put(`/users/${id}`, data)
+export const updateUser = (id, data, version) => api.put(`/users/${id}`, data, { headers: ifMatch(version) })
+export const approveUser = (id, role) => api.post(`/users/${id}/approve`, role ? { role } : undefined)
+export const rejectUser = (id) => api.post(`/users/${id}/reject`)
+export const resetUserTwoFactor = (id) => api.post(`/users/${id}/2fa/reset`)
+export const unlockUser = (id, ip) => api.post(`/users/${id}/unlock`, null, { params: { ip } })
 export const deleteUser = (id) => api.delete(`/users/${id}`)
 
-export const getAuditLogs = () => api.get('/audit')
+export const getAuditLogs = (params) => api.get('/audit', { params })
 
 export default api